@@ -0,0 +1,215 @@
+package ledger
+
+import (
+	"fmt"
+	"strings"
+)
+
+// The address returned by the device is the public key prefix, go-spacemesh account addresses
+// are hashes of the account template and its arguments. The prefixes differ from the go-spacemesh
+// ones "sm", "stest" and "standalone", so the encoded device addresses cannot be taken for accounts.
+const (
+	// AddressHRPMainnet Human-readable prefix of mainnet device addresses
+	AddressHRPMainnet = "smpk"
+	// AddressHRPTestnet Human-readable prefix of testnet device addresses
+	AddressHRPTestnet = "stestpk"
+	// AddressHRPStandalone Human-readable prefix of standalone network device addresses
+	AddressHRPStandalone = "standalonepk"
+
+	// Max length of bech32 string
+	cBech32MaxLength = 90
+	// Size of bech32 checksum in characters
+	cBech32ChecksumSize = 6
+	// Bech32 alphabet
+	cBech32Charset = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+)
+
+// Generator coefficients of bech32 checksum
+var bech32Generator = [5]uint32{0x3b6a57b2, 0x26508e6d, 0x1ea119fa, 0x3d4233dd, 0x2a1462b3}
+
+// AddressFromPublicKey Derive account address from the public key without device request,
+// the address is the first 20 bytes of the key, same as returned by GetAddress.
+//
+// param {[]byte} publicKey ed25519 public key, 32 bytes.
+// return {[]byte} The address.
+// return {error} Error value.
+func AddressFromPublicKey(publicKey []byte) ([]byte, error) {
+	if len(publicKey) != PublicKeySize {
+		return nil, fmt.Errorf("Wrong public key length: expected %v, got %v", PublicKeySize, len(publicKey))
+	}
+	return append([]byte{}, publicKey[:AddressSize]...), nil
+}
+
+// Address Derive account address of the public key, see AddressFromPublicKey
+func (publicKey *ExtendedPublicKey) Address() ([]byte, error) {
+	return AddressFromPublicKey(publicKey.PublicKey)
+}
+
+// EncodeAddress Encode device address as bech32 string with network prefix.
+// The result is not a go-spacemesh account address, see AddressHRPMainnet.
+//
+// param {string} hrp Human-readable prefix, e.g. AddressHRPMainnet.
+// param {[]byte} address The address, 20 bytes.
+// return {string} Encoded address, e.g. smpk1...
+// return {error} Error value.
+//
+// example
+// publicKey, err := device.GetExtendedPublicKey(ledger.SpacemeshPath(0, 0, 0))
+//
+//	if err == nil {
+//		address, _ := publicKey.Address()
+//		text, _ := ledger.EncodeAddress(ledger.AddressHRPMainnet, address)
+//		fmt.Printf("address: %v\n", text)
+//	}
+func EncodeAddress(hrp string, address []byte) (string, error) {
+	if len(address) != AddressSize {
+		return "", fmt.Errorf("Wrong address length: expected %v, got %v", AddressSize, len(address))
+	}
+	return bech32Encode(hrp, convertBits(address, 8, 5, true))
+}
+
+// DecodeAddress Decode bech32 device address and check its checksum, network prefix and length.
+// go-spacemesh account addresses are rejected, they have other prefixes and 24 bytes.
+//
+// param {string} hrp Expected human-readable prefix, e.g. AddressHRPMainnet.
+// param {string} text Encoded address.
+// return {[]byte} The address, 20 bytes, e.g. Recipient of TxHeader.
+// return {error} Error value.
+//
+// example
+// recipient, err := ledger.DecodeAddress(ledger.AddressHRPMainnet, userInput)
+//
+//	if err != nil {
+//		fmt.Printf("invalid recipient: %v\n", err)
+//	}
+func DecodeAddress(hrp string, text string) ([]byte, error) {
+	textHRP, data, err := bech32Decode(text)
+	if err != nil {
+		return nil, err
+	}
+	if textHRP != hrp {
+		return nil, fmt.Errorf("Wrong address prefix: expected %q, got %q", hrp, textHRP)
+	}
+	address := convertBits(data, 5, 8, false)
+	if address == nil {
+		return nil, fmt.Errorf("Invalid address padding")
+	}
+	if len(address) != AddressSize {
+		return nil, fmt.Errorf("Wrong address length: expected %v, got %v", AddressSize, len(address))
+	}
+	return address, nil
+}
+
+// Bech32 checksum of the values
+func bech32Polymod(values []byte) uint32 {
+	checksum := uint32(1)
+	for _, v := range values {
+		top := checksum >> 25
+		checksum = (checksum&0x1ffffff)<<5 ^ uint32(v)
+		for i, g := range bech32Generator {
+			if (top>>uint(i))&1 == 1 {
+				checksum ^= g
+			}
+		}
+	}
+	return checksum
+}
+
+// Expand human-readable prefix for checksum computation
+func bech32ExpandHRP(hrp string) []byte {
+	result := make([]byte, 0, 2*len(hrp)+1)
+	for i := 0; i < len(hrp); i++ {
+		result = append(result, hrp[i]>>5)
+	}
+	result = append(result, 0)
+	for i := 0; i < len(hrp); i++ {
+		result = append(result, hrp[i]&31)
+	}
+	return result
+}
+
+// Encode human-readable prefix and 5-bit values as bech32 string
+func bech32Encode(hrp string, data []byte) (string, error) {
+	if len(hrp) == 0 {
+		return "", fmt.Errorf("Address prefix is empty")
+	}
+	if len(hrp)+1+len(data)+cBech32ChecksumSize > cBech32MaxLength {
+		return "", fmt.Errorf("Address is too long")
+	}
+	for i := 0; i < len(hrp); i++ {
+		if hrp[i] < 33 || hrp[i] > 126 || (hrp[i] >= 'A' && hrp[i] <= 'Z') {
+			return "", fmt.Errorf("Invalid character %q in address prefix", hrp[i])
+		}
+	}
+
+	values := append(bech32ExpandHRP(hrp), data...)
+	checksum := bech32Polymod(append(values, make([]byte, cBech32ChecksumSize)...)) ^ 1
+
+	var sb strings.Builder
+	sb.WriteString(hrp)
+	sb.WriteString("1")
+	for _, v := range data {
+		sb.WriteByte(cBech32Charset[v])
+	}
+	for i := 0; i < cBech32ChecksumSize; i++ {
+		sb.WriteByte(cBech32Charset[(checksum>>uint(5*(5-i)))&31])
+	}
+	return sb.String(), nil
+}
+
+// Decode bech32 string to human-readable prefix and 5-bit values without checksum
+func bech32Decode(text string) (string, []byte, error) {
+	if len(text) > cBech32MaxLength {
+		return "", nil, fmt.Errorf("Address is too long: %v characters", len(text))
+	}
+	lower := strings.ToLower(text)
+	if lower != text && strings.ToUpper(text) != text {
+		return "", nil, fmt.Errorf("Address has mixed case")
+	}
+	separator := strings.LastIndexByte(lower, '1')
+	if separator < 1 || separator+1+cBech32ChecksumSize > len(lower) {
+		return "", nil, fmt.Errorf("Invalid address separator position")
+	}
+	hrp := lower[:separator]
+	for i := 0; i < len(hrp); i++ {
+		if hrp[i] < 33 || hrp[i] > 126 {
+			return "", nil, fmt.Errorf("Invalid character %q in address prefix", hrp[i])
+		}
+	}
+	data := make([]byte, 0, len(lower)-separator-1)
+	for i := separator + 1; i < len(lower); i++ {
+		v := strings.IndexByte(cBech32Charset, lower[i])
+		if v < 0 {
+			return "", nil, fmt.Errorf("Invalid character %q in address at position %v", text[i], i)
+		}
+		data = append(data, byte(v))
+	}
+	if bech32Polymod(append(bech32ExpandHRP(hrp), data...)) != 1 {
+		return "", nil, fmt.Errorf("Invalid address checksum")
+	}
+	return hrp, data[:len(data)-cBech32ChecksumSize], nil
+}
+
+// Regroup bits of the values, returns nil if the padding is invalid
+func convertBits(data []byte, fromBits, toBits uint, pad bool) []byte {
+	var acc uint32
+	var bits uint
+	maxValue := uint32(1)<<toBits - 1
+	result := make([]byte, 0, len(data)*int(fromBits)/int(toBits)+1)
+	for _, v := range data {
+		acc = acc<<fromBits | uint32(v)
+		bits += fromBits
+		for bits >= toBits {
+			bits -= toBits
+			result = append(result, byte((acc>>bits)&maxValue))
+		}
+	}
+	if pad {
+		if bits > 0 {
+			result = append(result, byte((acc<<(toBits-bits))&maxValue))
+		}
+	} else if bits >= fromBits || (acc<<(toBits-bits))&maxValue != 0 {
+		return nil
+	}
+	return result
+}
@@ -0,0 +1,113 @@
+package ledger
+
+import (
+	"bytes"
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+func TestBech32(t *testing.T) {
+	// BIP-173 test vectors
+	for _, text := range []string{
+		"A12UEL5L",
+		"a12uel5l",
+		"an83characterlonghumanreadablepartthatcontainsthenumber1andtheexcludedcharactersbio1tt5tgs",
+		"abcdef1qpzry9x8gf2tvdw0s3jn54khce6mua7lmqqqxw",
+		"11qqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqc8247j",
+		"split1checkupstagehandshakeupstreamerranterredcaperred2y9e3w",
+		"?1ezyfcl",
+	} {
+		hrp, data, err := bech32Decode(text)
+		if err != nil {
+			t.Fatalf("%v: decode ERROR: %v\n", text, err)
+		}
+		encoded, err := bech32Encode(hrp, data)
+		if err != nil || encoded != strings.ToLower(text) {
+			t.Fatalf("%v: WRONG encoded string %v: %v\n", text, encoded, err)
+		}
+	}
+	for _, text := range []string{
+		"\x201nwldj5",
+		"an84characterslonghumanreadablepartthatcontainsthenumber1andtheexcludedcharactersbio1569pvx",
+		"pzry9x0s0muk",
+		"1pzry9x0s0muk",
+		"x1b4n0q5v",
+		"li1dgmt3",
+		"de1lg7wt\xff",
+		"A1G7SGD8",
+		"10a06t8",
+		"1qzzfhee",
+		"a12UEL5L",
+	} {
+		if _, _, err := bech32Decode(text); err == nil {
+			t.Errorf("%q: decode of invalid string succeeded\n", text)
+		}
+	}
+}
+
+func TestAddress(t *testing.T) {
+	device := NewLedger(newTestEmulator(t))
+	path := SpacemeshPath(0, 0, 0)
+	publicKey, err := device.GetExtendedPublicKey(path)
+	if err != nil {
+		t.Fatalf("get public key ERROR: %v\n", err)
+	}
+	expected, err := device.GetAddress(path)
+	if err != nil {
+		t.Fatalf("get address ERROR: %v\n", err)
+	}
+	address, err := publicKey.Address()
+	if err != nil || !bytes.Equal(address, expected) {
+		t.Fatalf("WRONG address %x, expected %x: %v\n", address, expected, err)
+	}
+	if _, err := AddressFromPublicKey(address); err == nil {
+		t.Fatalf("address from short public key succeeded\n")
+	}
+
+	text, err := EncodeAddress(AddressHRPMainnet, address)
+	if err != nil || !strings.HasPrefix(text, "smpk1") {
+		t.Fatalf("WRONG encoded address %v: %v\n", text, err)
+	}
+	decoded, err := DecodeAddress(AddressHRPMainnet, strings.ToUpper(text))
+	if err != nil || !bytes.Equal(decoded, address) {
+		t.Fatalf("decode ERROR: %v\n", err)
+	}
+	if _, err := DecodeAddress(AddressHRPTestnet, text); err == nil || err.Error() != `Wrong address prefix: expected "stestpk", got "smpk"` {
+		t.Fatalf("WRONG prefix error: %v\n", err)
+	}
+
+	// Typo in the address breaks the checksum
+	typo := []byte(text)
+	typo[10] = cBech32Charset[(strings.IndexByte(cBech32Charset, typo[10])+1)%32]
+	if _, err := DecodeAddress(AddressHRPMainnet, string(typo)); err == nil || err.Error() != "Invalid address checksum" {
+		t.Fatalf("WRONG checksum error: %v\n", err)
+	}
+
+	publicKeyText, _ := hex.DecodeString(testPublicKey)
+	short, _ := bech32Encode(AddressHRPMainnet, convertBits(publicKeyText, 8, 5, true))
+	if _, err := DecodeAddress(AddressHRPMainnet, short); err == nil || err.Error() != "Wrong address length: expected 20, got 32" {
+		t.Fatalf("WRONG length error: %v\n", err)
+	}
+	if _, err := EncodeAddress(AddressHRPMainnet, publicKeyText); err == nil {
+		t.Fatalf("encode of long address succeeded\n")
+	}
+}
+
+func TestGoSpacemeshAddress(t *testing.T) {
+	// Account address generated by go-spacemesh, 4 zero bytes followed by 20 bytes of hash
+	text := "stest1qqqqqqrs60l66w5uksxzmaznwq6xnhqfv56c28qlkm4a5"
+	if _, err := DecodeAddress(AddressHRPTestnet, text); err == nil || err.Error() != `Wrong address prefix: expected "stestpk", got "stest"` {
+		t.Fatalf("WRONG prefix error: %v\n", err)
+	}
+	if _, err := DecodeAddress("stest", text); err == nil || err.Error() != "Wrong address length: expected 20, got 24" {
+		t.Fatalf("WRONG length error: %v\n", err)
+	}
+
+	// Device address is not encoded in the go-spacemesh layout
+	address, _ := hex.DecodeString(testPublicKey[:2*AddressSize])
+	encoded, err := EncodeAddress(AddressHRPTestnet, address)
+	if err != nil || encoded != "stestpk153ag3q2van0y9u4dp463y084xra73ev50dcqwn" {
+		t.Fatalf("WRONG encoded address %v: %v\n", encoded, err)
+	}
+}
@@ -0,0 +1,121 @@
+package ledger
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+)
+
+// StatusOK Status word of the successfully processed request
+const StatusOK = cSwOK
+
+// Command APDU command with short header: CLA, INS, P1, P2, data length and data
+type Command struct {
+	// Application identifier
+	CLA byte
+	// Instruction
+	INS byte
+	// Parameter 1
+	P1 byte
+	// Parameter 2
+	P2 byte
+	// Payload, up to 255 bytes
+	Data []byte
+}
+
+// Response APDU response
+type Response struct {
+	// Response data without status word
+	Data []byte
+	// Status word
+	SW uint16
+}
+
+// Marshal Encode command to APDU bytes
+func (command *Command) Marshal() ([]byte, error) {
+	if len(command.Data) >= 256 {
+		return nil, fmt.Errorf("DataLengthTooBig: data.length exceed 256 bytes limit. Got: %v", len(command.Data))
+	}
+	buffer := make([]byte, 5+len(command.Data))
+	buffer[0] = command.CLA
+	buffer[1] = command.INS
+	buffer[2] = command.P1
+	buffer[3] = command.P2
+	buffer[4] = byte(len(command.Data))
+	copy(buffer[5:], command.Data)
+	return buffer, nil
+}
+
+// Unmarshal Decode command from APDU bytes
+func (command *Command) Unmarshal(apdu []byte) error {
+	if len(apdu) < 5 {
+		return fmt.Errorf("Wrong command length: expected at least 5, got %v", len(apdu))
+	}
+	if len(apdu) != 5+int(apdu[4]) {
+		return fmt.Errorf("Wrong command length: expected %v, got %v", 5+int(apdu[4]), len(apdu))
+	}
+	command.CLA, command.INS, command.P1, command.P2 = apdu[0], apdu[1], apdu[2], apdu[3]
+	command.Data = append([]byte{}, apdu[5:]...)
+	return nil
+}
+
+// Marshal Encode response to bytes: data followed by status word
+func (response *Response) Marshal() ([]byte, error) {
+	buffer := make([]byte, len(response.Data)+2)
+	copy(buffer, response.Data)
+	binary.BigEndian.PutUint16(buffer[len(response.Data):], response.SW)
+	return buffer, nil
+}
+
+// Unmarshal Decode response from bytes returned by the device
+func (response *Response) Unmarshal(data []byte) error {
+	if len(data) < 2 {
+		return fmt.Errorf("Wrong response length: expected at least 2, got %v", len(data))
+	}
+	response.Data = append([]byte{}, data[:len(data)-2]...)
+	response.SW = binary.BigEndian.Uint16(data[len(data)-2:])
+	return nil
+}
+
+// Err Map status word to error: nil for StatusOK, StatusError otherwise
+func (response *Response) Err() error {
+	if response.SW == StatusOK {
+		return nil
+	}
+	return &StatusError{SW: response.SW, Response: response.Data}
+}
+
+// Exchange Send raw APDU command to the device.
+// The status word is not checked, use Response.Err to map it to error.
+//
+// param {Command} command The command.
+// return {Response} The response with status word.
+// return {error} Error value of the transport.
+//
+// example
+// response, err := device.Exchange(ledger.Command{CLA: 0xB0, INS: 0x01})
+//
+//	if err == nil {
+//		err = response.Err()
+//	}
+func (device *Ledger) Exchange(command Command) (Response, error) {
+	return device.ExchangeContext(context.Background(), command)
+}
+
+// ExchangeContext Same as Exchange, waiting for the response is aborted
+// when the context is done, CanceledError is returned.
+func (device *Ledger) ExchangeContext(ctx context.Context, command Command) (Response, error) {
+	apdu, err := command.Marshal()
+	if err != nil {
+		return Response{}, err
+	}
+	data, err := device.exchange(ctx, apdu)
+	if err != nil {
+		return Response{}, err
+	}
+	var response Response
+	if err := response.Unmarshal(data); err != nil {
+		return Response{}, err
+	}
+	return response, nil
+}
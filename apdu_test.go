@@ -0,0 +1,58 @@
+package ledger
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestCommand(t *testing.T) {
+	command := Command{CLA: cCLA, INS: cInsGetAddress, P1: cP1Return, P2: cP2Unused, Data: []byte{1, 2, 3}}
+	apdu, err := command.Marshal()
+	if err != nil || !bytes.Equal(apdu, []byte{0x30, 0x11, 0x01, 0x00, 0x03, 1, 2, 3}) {
+		t.Fatalf("WRONG command bytes %x: %v\n", apdu, err)
+	}
+	var decoded Command
+	if err := decoded.Unmarshal(apdu); err != nil || decoded.INS != command.INS || !bytes.Equal(decoded.Data, command.Data) {
+		t.Fatalf("unmarshal ERROR: %v, %+v\n", err, decoded)
+	}
+	if err := decoded.Unmarshal(apdu[:7]); err == nil || err.Error() != "Wrong command length: expected 8, got 7" {
+		t.Fatalf("WRONG unmarshal error: %v\n", err)
+	}
+	if _, err := (&Command{Data: make([]byte, 256)}).Marshal(); err == nil {
+		t.Fatalf("marshal of too long command succeeded\n")
+	}
+
+	response := Response{Data: []byte{1, 2}, SW: cSwRejected}
+	data, err := response.Marshal()
+	if err != nil || !bytes.Equal(data, []byte{1, 2, 0x6e, 0x09}) {
+		t.Fatalf("WRONG response bytes %x: %v\n", data, err)
+	}
+	var decodedResponse Response
+	if err := decodedResponse.Unmarshal(data); err != nil || decodedResponse.SW != cSwRejected || !bytes.Equal(decodedResponse.Data, response.Data) {
+		t.Fatalf("unmarshal ERROR: %v, %+v\n", err, decodedResponse)
+	}
+	if err := decodedResponse.Unmarshal(data[:1]); err == nil {
+		t.Fatalf("unmarshal of short response succeeded\n")
+	}
+	if !errors.Is(response.Err(), ErrUserRejected) || (&Response{SW: StatusOK}).Err() != nil {
+		t.Fatalf("WRONG status mapping\n")
+	}
+}
+
+func TestLedgerExchange(t *testing.T) {
+	device := NewLedger(newTestEmulator(t))
+	response, err := device.Exchange(Command{CLA: cCLA, INS: cInsGetVersion})
+	if err != nil || response.SW != StatusOK || !bytes.Equal(response.Data, []byte{0, 0, 4, 0}) {
+		t.Fatalf("exchange ERROR: %v, %+v\n", err, response)
+	}
+
+	// Error status word is returned as is
+	response, err = device.Exchange(Command{CLA: cCLA, INS: 0x7f})
+	if err != nil || response.SW != cSwUnknownIns {
+		t.Fatalf("WRONG response: %v, %+v\n", err, response)
+	}
+	if !errors.Is(response.Err(), ErrUnknownInstruction) {
+		t.Fatalf("WRONG status error: %v\n", response.Err())
+	}
+}
@@ -0,0 +1,129 @@
+package ledger
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestContextCancel(t *testing.T) {
+	emulator := newTestEmulator(t)
+	confirm := make(chan bool)
+	emulator.User = EmulatorUserFunc(func(screens []EmulatorScreen) bool {
+		return <-confirm
+	})
+	device := NewLedger(emulator)
+	path := StringToPath("44'/540'/0'/0/0'")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := device.GetExtendedPublicKeyContext(ctx, path)
+	var canceled *CanceledError
+	if !errors.As(err, &canceled) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("WRONG cancel error: %v\n", err)
+	}
+	// The user dismisses the request displayed on the device
+	confirm <- false
+
+	publicKey, _ := hex.DecodeString(testPublicKey)
+	tx, err := (&ExecAppTx{
+		TxHeader: TxHeader{
+			NetworkID: make([]byte, NetworkIDSize),
+			Recipient: make([]byte, AddressSize),
+			PublicKey: publicKey,
+		},
+		Data: make([]byte, 1024),
+	}).Encode()
+	if err != nil {
+		t.Fatalf("encode tx ERROR: %v\n", err)
+	}
+	_, err = device.SignTxContext(ctx, path, tx)
+	if !errors.As(err, &canceled) {
+		t.Fatalf("WRONG cancel error: %v\n", err)
+	}
+
+	if _, err := device.GetVersionContext(context.Background()); err != nil {
+		t.Fatalf("get version ERROR: %v\n", err)
+	}
+}
+
+func TestContextCancelSpeculos(t *testing.T) {
+	server := NewSpeculosServer(NewEmulator(MnemonicToSeed("secret", "")))
+	if err := server.Start("127.0.0.1:0"); err != nil {
+		t.Fatalf("start server ERROR: %v\n", err)
+	}
+	defer server.Close()
+	device := NewLedger(NewSpeculos(server.URL()))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		cancel()
+	}()
+	err := device.ShowAddressContext(ctx, StringToPath("44'/540'/0'/0/0'"))
+	var canceled *CanceledError
+	if !errors.As(err, &canceled) || !errors.Is(err, context.Canceled) {
+		t.Fatalf("WRONG cancel error: %v\n", err)
+	}
+}
+
+// Device reporting concurrent calls of Exchange
+type testSerialDevice struct {
+	IHidDevice
+	active  int32
+	overlap int32
+}
+
+func (device *testSerialDevice) Exchange(apdu []byte) ([]byte, error) {
+	if atomic.AddInt32(&device.active, 1) > 1 {
+		atomic.StoreInt32(&device.overlap, 1)
+	}
+	defer atomic.AddInt32(&device.active, -1)
+	return device.IHidDevice.Exchange(apdu)
+}
+
+func TestContextCancelWaitsForAbandonedExchange(t *testing.T) {
+	emulator := newTestEmulator(t)
+	confirm := make(chan bool)
+	emulator.User = EmulatorUserFunc(func(screens []EmulatorScreen) bool {
+		return <-confirm
+	})
+	hid := &testSerialDevice{IHidDevice: emulator}
+	device := NewLedger(hid)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := device.GetExtendedPublicKeyContext(ctx, StringToPath("44'/540'/0'/0/0'"))
+	var canceled *CanceledError
+	if !errors.As(err, &canceled) {
+		t.Fatalf("WRONG cancel error: %v\n", err)
+	}
+
+	// The abandoned exchange is still in progress
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := device.GetVersionContext(ctx); !errors.As(err, &canceled) {
+		t.Fatalf("WRONG cancel error: %v\n", err)
+	}
+	done := make(chan error, 1)
+	go func() {
+		_, err := device.GetVersion()
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		t.Fatalf("exchange did not wait for the abandoned one: %v\n", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	confirm <- true
+	if err := <-done; err != nil {
+		t.Fatalf("get version ERROR: %v\n", err)
+	}
+	if atomic.LoadInt32(&hid.overlap) != 0 {
+		t.Fatalf("WRONG concurrent exchanges\n")
+	}
+}
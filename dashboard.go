@@ -0,0 +1,134 @@
+package ledger
+
+import (
+	"context"
+	"fmt"
+)
+
+const (
+	// SpacemeshAppName Name of the Spacemesh app installed on the device
+	SpacemeshAppName = "Spacemesh"
+	// DashboardAppName Name reported by GetAppInfo when no app is running
+	DashboardAppName = "BOLOS"
+
+	// Application Identifier of the OS commands
+	cCLAOS = 0xB0
+	// Application Identifier of the dashboard commands
+	cCLADashboard = 0xE0
+	// Get name and version of the running app
+	cInsGetAppInfo = 0x01
+	// Quit the running app
+	cInsQuitApp = 0xA7
+	// Open app by name
+	cInsOpenApp = 0xD8
+	// Format of the GetAppInfo response
+	cAppInfoFormat = 1
+)
+
+// AppInfo Name and version of the app running on the device
+type AppInfo struct {
+	// App name, DashboardAppName if no app is running
+	Name string
+	// App version
+	Version string
+	// App flags
+	Flags []byte
+}
+
+// IsDashboard Reports whether no app is running
+func (info *AppInfo) IsDashboard() bool {
+	return info.Name == DashboardAppName
+}
+
+// Decode GetAppInfo response: format, name, version and flags with length prefixes
+func parseAppInfo(data []byte) (*AppInfo, error) {
+	if len(data) < 1 || data[0] != cAppInfoFormat {
+		return nil, fmt.Errorf("Wrong app info format")
+	}
+	fields := make([][]byte, 0, 3)
+	offset := 1
+	for offset < len(data) && len(fields) < 3 {
+		size := int(data[offset])
+		if offset+1+size > len(data) {
+			return nil, fmt.Errorf("App info is truncated")
+		}
+		fields = append(fields, data[offset+1:offset+1+size])
+		offset += 1 + size
+	}
+	if len(fields) < 2 {
+		return nil, fmt.Errorf("App info is truncated")
+	}
+	info := &AppInfo{Name: string(fields[0]), Version: string(fields[1])}
+	if len(fields) > 2 {
+		info.Flags = append([]byte{}, fields[2]...)
+	}
+	return info, nil
+}
+
+// Encode GetAppInfo response
+func encodeAppInfo(info *AppInfo) []byte {
+	data := []byte{cAppInfoFormat}
+	for _, field := range [][]byte{[]byte(info.Name), []byte(info.Version), info.Flags} {
+		data = append(data, byte(len(field)))
+		data = append(data, field...)
+	}
+	return data
+}
+
+// GetAppInfo Get name and version of the app running on the device, works in the dashboard and in any app.
+//
+// return {*AppInfo} The running app, DashboardAppName if no app is running.
+// return {error} Error value.
+//
+// example
+// info, err := device.GetAppInfo()
+//
+//	if err == nil && info.Name != ledger.SpacemeshAppName {
+//		fmt.Printf("you are in the %v app\n", info.Name)
+//	}
+func (device *Ledger) GetAppInfo() (*AppInfo, error) {
+	return device.GetAppInfoContext(context.Background())
+}
+
+// GetAppInfoContext Same as GetAppInfo with the context
+func (device *Ledger) GetAppInfoContext(ctx context.Context) (*AppInfo, error) {
+	response, err := device.send(ctx, cCLAOS, cInsGetAppInfo, 0, 0, nil)
+	if err != nil {
+		return nil, err
+	}
+	return parseAppInfo(response)
+}
+
+// OpenApp Open the app from the dashboard, the user may have to confirm it on the device.
+// Returns ErrAppNotInstalled if there is no such app and ErrUserRejected if the user denied it.
+// Another app must be closed with QuitApp first.
+//
+// param {string} name The app name, e.g. SpacemeshAppName.
+// return {error} Error value.
+//
+// example
+// info, err := device.GetAppInfo()
+//
+//	if err == nil && info.IsDashboard() {
+//		err = device.OpenApp(ledger.SpacemeshAppName)
+//	}
+func (device *Ledger) OpenApp(name string) error {
+	return device.OpenAppContext(context.Background(), name)
+}
+
+// OpenAppContext Same as OpenApp, waiting for the user confirmation is aborted when the context is done
+func (device *Ledger) OpenAppContext(ctx context.Context, name string) error {
+	_, err := device.send(ctx, cCLADashboard, cInsOpenApp, 0, 0, []byte(name))
+	return err
+}
+
+// QuitApp Quit the running app and return to the dashboard
+func (device *Ledger) QuitApp() error {
+	return device.QuitAppContext(context.Background())
+}
+
+// QuitAppContext Same as QuitApp with the context
+func (device *Ledger) QuitAppContext(ctx context.Context) error {
+	_, err := device.send(ctx, cCLAOS, cInsQuitApp, 0, 0, nil)
+	return err
+}
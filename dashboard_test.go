@@ -0,0 +1,56 @@
+package ledger
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestDashboard(t *testing.T) {
+	emulator := newTestEmulator(t)
+	device := NewLedger(emulator)
+
+	info, err := device.GetAppInfo()
+	if err != nil || info.Name != SpacemeshAppName || info.Version != "0.0.4" || info.IsDashboard() {
+		t.Fatalf("get app info ERROR: %v, %+v\n", err, info)
+	}
+
+	if err := device.QuitApp(); err != nil {
+		t.Fatalf("quit app ERROR: %v\n", err)
+	}
+	info, err = device.GetAppInfo()
+	if err != nil || !info.IsDashboard() {
+		t.Fatalf("get app info ERROR: %v, %+v\n", err, info)
+	}
+	if _, err := device.GetVersion(); !errors.Is(err, ErrAppNotOpen) {
+		t.Fatalf("WRONG error in dashboard: %v\n", err)
+	}
+
+	if err := device.OpenApp("Bitcoin"); !errors.Is(err, ErrAppNotInstalled) {
+		t.Fatalf("WRONG open unknown app error: %v\n", err)
+	}
+	emulator.User = EmulatorUserFunc(func(screens []EmulatorScreen) bool {
+		return false
+	})
+	if err := device.OpenApp(SpacemeshAppName); !errors.Is(err, ErrUserRejected) {
+		t.Fatalf("WRONG denied open app error: %v\n", err)
+	}
+	emulator.User = nil
+	if err := device.OpenApp(SpacemeshAppName); err != nil {
+		t.Fatalf("open app ERROR: %v\n", err)
+	}
+	if _, err := device.GetVersion(); err != nil {
+		t.Fatalf("get version ERROR: %v\n", err)
+	}
+}
+
+func TestParseAppInfo(t *testing.T) {
+	info, err := parseAppInfo([]byte{1, 5, 'B', 'O', 'L', 'O', 'S', 5, '2', '.', '1', '.', '0'})
+	if err != nil || info.Name != "BOLOS" || info.Version != "2.1.0" || info.Flags != nil {
+		t.Fatalf("parse ERROR: %v, %+v\n", err, info)
+	}
+	for _, data := range [][]byte{nil, {2, 0, 0}, {1, 5, 'B'}, {1, 1, 'B'}} {
+		if _, err := parseAppInfo(data); err == nil {
+			t.Errorf("%x: parse of invalid app info succeeded\n", data)
+		}
+	}
+}
@@ -0,0 +1,249 @@
+package ledger
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/binary"
+	"fmt"
+	"math/big"
+
+	"github.com/spacemeshos/ed25519"
+)
+
+const (
+	// HMAC key used to expand the seed into the master node
+	cEd25519SeedKey = "ed25519 seed"
+	// Number of PBKDF2 iterations used to convert BIP39 mnemonic to seed
+	cMnemonicIterations = 2048
+	// Size of BIP39 seed
+	cSeedSize = 64
+)
+
+// Derived key tree node
+type keyNode struct {
+	// kL || kR extended private key
+	key []byte
+	// Chain code
+	chainCode []byte
+}
+
+// Curve25519 field prime and twisted Edwards curve parameters
+var (
+	edP     = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 255), big.NewInt(19))
+	edD     = new(big.Int).Mod(new(big.Int).Mul(big.NewInt(-121665), new(big.Int).ModInverse(big.NewInt(121666), edP)), edP)
+	edBaseX = bigFromString("15112221349535400772501151409588531511454012693041857206046113283949847762202")
+	edBaseY = bigFromString("46316835694926478169428394003475163141307993866256225615783033603165251855960")
+)
+
+// Parse decimal integer constant
+func bigFromString(s string) *big.Int {
+	v, _ := new(big.Int).SetString(s, 10)
+	return v
+}
+
+// Compute HMAC-SHA512
+func hmacSha512(key []byte, data ...[]byte) []byte {
+	mac := hmac.New(sha512.New, key)
+	for _, d := range data {
+		mac.Write(d)
+	}
+	return mac.Sum(nil)
+}
+
+// Convert little endian bytes to integer
+func leToInt(b []byte) *big.Int {
+	be := make([]byte, len(b))
+	for i := range b {
+		be[len(b)-1-i] = b[i]
+	}
+	return new(big.Int).SetBytes(be)
+}
+
+// Convert integer to little endian bytes array of the given size, extra high bytes are truncated
+func intToLe(v *big.Int, size int) []byte {
+	be := v.Bytes()
+	le := make([]byte, size)
+	for i := 0; i < len(be) && i < size; i++ {
+		le[i] = be[len(be)-1-i]
+	}
+	return le
+}
+
+// Add two points on the twisted Edwards curve in affine coordinates
+func edAdd(x1, y1, x2, y2 *big.Int) (*big.Int, *big.Int) {
+	x1y2 := new(big.Int).Mul(x1, y2)
+	y1x2 := new(big.Int).Mul(y1, x2)
+	x1x2 := new(big.Int).Mul(x1, x2)
+	y1y2 := new(big.Int).Mul(y1, y2)
+	t := new(big.Int).Mul(x1x2, y1y2)
+	t.Mul(t, edD).Mod(t, edP)
+
+	dx := new(big.Int).Add(big.NewInt(1), t)
+	dx.ModInverse(dx.Mod(dx, edP), edP)
+	dy := new(big.Int).Sub(big.NewInt(1), t)
+	dy.ModInverse(dy.Mod(dy, edP), edP)
+
+	x := x1y2.Add(x1y2, y1x2)
+	x.Mul(x, dx).Mod(x, edP)
+	y := y1y2.Add(y1y2, x1x2)
+	y.Mul(y, dy).Mod(y, edP)
+	return x, y
+}
+
+// Multiply the curve base point by the raw (not clamped) little endian scalar
+// and return the encoded point.
+func edScalarMultBase(scalar []byte) []byte {
+	x, y := big.NewInt(0), big.NewInt(1)
+	bx, by := new(big.Int).Set(edBaseX), new(big.Int).Set(edBaseY)
+	k := leToInt(scalar)
+	for i := 0; i < k.BitLen(); i++ {
+		if k.Bit(i) == 1 {
+			x, y = edAdd(x, y, bx, by)
+		}
+		bx, by = edAdd(bx, by, bx, by)
+	}
+	encoded := intToLe(y, 32)
+	encoded[31] |= byte(x.Bit(0) << 7)
+	return encoded
+}
+
+// Expand seed into the master node
+func masterNode(seed []byte) *keyNode {
+	key := []byte(cEd25519SeedKey)
+	chainCode := hmac.New(sha256.New, key)
+	chainCode.Write([]byte{1})
+	chainCode.Write(seed)
+
+	I := hmacSha512(key, seed)
+	for I[31]&0x20 != 0 {
+		I = hmacSha512(key, I)
+	}
+	I[0] &= 0xf8
+	I[31] = (I[31] & 0x7f) | 0x40
+
+	return &keyNode{key: I, chainCode: chainCode.Sum(nil)}
+}
+
+// Derive child node with the given index
+func (node *keyNode) child(index uint32) *keyNode {
+	indexBytes := make([]byte, 4)
+	binary.LittleEndian.PutUint32(indexBytes, index)
+
+	var z, c []byte
+	if index&Hardened != 0 {
+		z = hmacSha512(node.chainCode, []byte{0x00}, node.key, indexBytes)
+		c = hmacSha512(node.chainCode, []byte{0x01}, node.key, indexBytes)
+	} else {
+		A := edScalarMultBase(node.key[:32])
+		z = hmacSha512(node.chainCode, []byte{0x02}, A, indexBytes)
+		c = hmacSha512(node.chainCode, []byte{0x03}, A, indexBytes)
+	}
+
+	kL := leToInt(z[:28])
+	kL.Mul(kL, big.NewInt(8)).Add(kL, leToInt(node.key[:32]))
+	kR := leToInt(z[32:])
+	kR.Add(kR, leToInt(node.key[32:]))
+
+	key := make([]byte, 64)
+	copy(key, intToLe(kL, 32))
+	copy(key[32:], intToLe(kR, 32))
+	return &keyNode{key: key, chainCode: c[32:]}
+}
+
+// Signing key of the node
+func (node *keyNode) privateKey() ed25519.PrivateKey {
+	return ed25519.NewKeyFromSeed(node.key[:32])
+}
+
+// Public key of the node
+func (node *keyNode) publicKey() []byte {
+	return node.privateKey().Public().(ed25519.PublicKey)
+}
+
+// PBKDF2 key derivation with HMAC-SHA512 as pseudorandom function
+func pbkdf2Sha512(password, salt []byte, iterations, keyLength int) []byte {
+	key := make([]byte, 0, keyLength)
+	block := make([]byte, 4)
+	for i := uint32(1); len(key) < keyLength; i++ {
+		binary.BigEndian.PutUint32(block, i)
+		u := hmacSha512(password, salt, block)
+		t := append([]byte{}, u...)
+		for n := 1; n < iterations; n++ {
+			u = hmacSha512(password, u)
+			for j := range t {
+				t[j] ^= u[j]
+			}
+		}
+		key = append(key, t...)
+	}
+	return key[:keyLength]
+}
+
+// Derive key tree node for the BIP 32 path the same way as the Ledger device does
+func deriveNode(seed []byte, path BipPath) (*keyNode, error) {
+	if len(seed) == 0 {
+		return nil, fmt.Errorf("Empty seed")
+	}
+	node := masterNode(seed)
+	for _, index := range path {
+		node = node.child(index)
+	}
+	return node, nil
+}
+
+// MnemonicToSeed Convert BIP39 mnemonic to seed.
+//
+// param {string} mnemonic Mnemonic words separated by spaces, must be in NFKD form (ASCII words are).
+// param {string} passphrase Optional passphrase, empty string if not used.
+// return {[]byte} 64 bytes seed.
+//
+// example
+// seed := ledger.MnemonicToSeed("secret", "")
+func MnemonicToSeed(mnemonic, passphrase string) []byte {
+	return pbkdf2Sha512([]byte(mnemonic), []byte("mnemonic"+passphrase), cMnemonicIterations, cSeedSize)
+}
+
+// DeriveExtendedPublicKey Derive the public key with chaincode from the seed without a device.
+// The seed is expanded into the master node with the SLIP-10 "ed25519 seed" key,
+// children are derived with BIP32-Ed25519 the same way as the Ledger device does,
+// so the result equals the one returned by GetExtendedPublicKey for the same path.
+//
+// param {[]byte} seed BIP39 seed.
+// param {BipPath} path The BIP 32 path indexes.
+// return {ExtendedPublicKey} The public key with chaincode for the given path.
+// return {error} Error value.
+//
+// example
+// publicKey, err := ledger.DeriveExtendedPublicKey(ledger.MnemonicToSeed("secret", ""), ledger.StringToPath("44'/540'/0'/0/0'"))
+//
+//	if err != nil {
+//		fmt.Printf("derive public key ERROR: %v\n", err)
+//	} else {
+//
+//		fmt.Printf("public key: %+v\n", publicKey)
+//	}
+func DeriveExtendedPublicKey(seed []byte, path BipPath) (*ExtendedPublicKey, error) {
+	node, err := deriveNode(seed, path)
+	if err != nil {
+		return nil, err
+	}
+	return &ExtendedPublicKey{
+		PublicKey: node.publicKey(),
+		ChainCode: node.chainCode,
+	}, nil
+}
+
+// DerivePrivateKey Derive the signing key from the seed without a device.
+//
+// param {[]byte} seed BIP39 seed.
+// param {BipPath} path The BIP 32 path indexes.
+// return {ed25519.PrivateKey} The signing key for the given path.
+// return {error} Error value.
+func DerivePrivateKey(seed []byte, path BipPath) (ed25519.PrivateKey, error) {
+	node, err := deriveNode(seed, path)
+	if err != nil {
+		return nil, err
+	}
+	return node.privateKey(), nil
+}
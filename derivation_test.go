@@ -0,0 +1,83 @@
+package ledger
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+func TestMnemonicToSeed(t *testing.T) {
+	tests := []struct {
+		mnemonic   string
+		passphrase string
+		seed       string
+	}{
+		{"secret", "", testSeed},
+		{
+			"abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about",
+			"TREZOR",
+			"c55257c360c07c72029aebc1b53c05ed0362ada38ead3e3e9efa3708e53495531f09a6987599d18264c1e1c92f2cf141630c7a3c4ab7c81b2f001698e7463b04",
+		},
+	}
+	for _, test := range tests {
+		if seed := hex.EncodeToString(MnemonicToSeed(test.mnemonic, test.passphrase)); seed != test.seed {
+			t.Errorf("WRONG seed for %q: %v\n", test.mnemonic, seed)
+		}
+	}
+}
+
+func TestDeriveExtendedPublicKey(t *testing.T) {
+	seed := MnemonicToSeed("secret", "")
+	// Key exported by the app on Speculos, the path has the non-hardened change index 0
+	publicKey, err := DeriveExtendedPublicKey(seed, StringToPath("44'/540'/0'/0/0'"))
+	if err != nil {
+		t.Fatalf("derive public key ERROR: %v\n", err)
+	}
+	if key := hex.EncodeToString(publicKey.PublicKey); key != testPublicKey {
+		t.Fatalf("WRONG public key: %v\n", key)
+	}
+	if chainCode := hex.EncodeToString(publicKey.ChainCode); chainCode != "5b8a438f52626879ca201f8d2e938dad5f1e99c01ae67a8bd4f805e25ab74630" {
+		t.Fatalf("WRONG chain code: %v\n", chainCode)
+	}
+
+	privateKey, err := DerivePrivateKey(seed, StringToPath("44'/540'/0'/0/0'"))
+	if err != nil {
+		t.Fatalf("derive private key ERROR: %v\n", err)
+	}
+	if !bytes.Equal(privateKey[32:], publicKey.PublicKey) {
+		t.Fatalf("private key does not match public key\n")
+	}
+
+	if _, err := DeriveExtendedPublicKey(nil, StringToPath("44'/540'")); err == nil {
+		t.Fatalf("derivation from empty seed should fail\n")
+	}
+}
+
+func TestDeriveGoldenVectors(t *testing.T) {
+	seed := MnemonicToSeed("secret", "")
+	// The change index of Spacemesh paths is not hardened, the keys of 44'/540'/a'/c/i' paths
+	// are the ones checked against the app by TestSpeculosDerivation
+	tests := []struct {
+		path      string
+		publicKey string
+		chainCode string
+	}{
+		{"44'/540'/0'/0", "34e91e0cd68d6c984f99d3d8619a294cb5e1d7f34f46379695582609a137fa49", "7ff66f8c81d15d2ae4092d7ea9e5daf8da1940bebd00f50e05f6ef038c298da8"},
+		{"44'/540'/0'/0/1'", "3e598631783f52215a1a86dea9a28e83ae31e7c19d59214b462586385258727f", "f3ca37a00aac3466ef44268b82ff1e3efc14dfe0cf924c813336646290eb1848"},
+		{"44'/540'/1'/0/0'", "d001d92cb920070c50ec1ca51fc7c7046c098948c6f3c5acff308c8823d4631e", "9d203470ddd09e6b779ea8cd993744af98913a518def27568d3ec706859b270b"},
+		{"44'/540'/3'/1/7'", "472fa92532d7073c08f53c31d08da826dbde9e0f4d41f3259eacb694bf3b4aac", "fbe22c4b58408d94a5d33338d0b7a480d295f68cc048fd21035108893759978e"},
+		{"44'/540'/2'/3/4", "648a50c05a120484403cc43d10ea1c9efc00ea06f5552e0fc5af981684b15f2b", "a290b484ddb5a68b2be8c7eb8ca80419e2c852d0af43a8eeba45bdec55ed1733"},
+	}
+	for _, test := range tests {
+		publicKey, err := DeriveExtendedPublicKey(seed, StringToPath(test.path))
+		if err != nil {
+			t.Fatalf("%v: derive public key ERROR: %v\n", test.path, err)
+		}
+		if key := hex.EncodeToString(publicKey.PublicKey); key != test.publicKey {
+			t.Errorf("%v: WRONG public key: %v\n", test.path, key)
+		}
+		if chainCode := hex.EncodeToString(publicKey.ChainCode); chainCode != test.chainCode {
+			t.Errorf("%v: WRONG chain code: %v\n", test.path, chainCode)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package ledger
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+)
+
+const (
+	// Get device info in the dashboard
+	cInsGetDeviceInfo = 0x01
+)
+
+// DeviceModel Ledger device model
+type DeviceModel struct {
+	// Model identifier, e.g. "nanoS"
+	ID string
+	// Product name, e.g. "Nano S"
+	Name string
+	// Legacy USB product id
+	LegacyProductID uint16
+	// High byte of the USB product id, the low byte is the interface mask
+	ProductIDPrefix byte
+	// Target id reported by GetDeviceInfo, without the firmware specific low byte
+	TargetID uint32
+}
+
+// DeviceModels Known Ledger device models
+var DeviceModels = []DeviceModel{
+	{ID: "nanoS", Name: "Nano S", LegacyProductID: 0x0001, ProductIDPrefix: 0x10, TargetID: 0x31100000},
+	{ID: "nanoX", Name: "Nano X", LegacyProductID: 0x0004, ProductIDPrefix: 0x40, TargetID: 0x33000000},
+	{ID: "nanoSP", Name: "Nano S Plus", LegacyProductID: 0x0005, ProductIDPrefix: 0x50, TargetID: 0x33100000},
+	{ID: "stax", Name: "Stax", LegacyProductID: 0x0006, ProductIDPrefix: 0x60, TargetID: 0x33200000},
+}
+
+// Model Detect device model by USB product id, nil if the model is not known
+func (info *HidDeviceInfo) Model() *DeviceModel {
+	for i := range DeviceModels {
+		model := &DeviceModels[i]
+		if info.ProductID == model.LegacyProductID || byte(info.ProductID>>8) == model.ProductIDPrefix {
+			return model
+		}
+	}
+	return nil
+}
+
+// DeviceInfo Firmware information reported by the dashboard
+type DeviceInfo struct {
+	// Target id of the device
+	TargetID uint32
+	// Secure element firmware version
+	SEVersion string
+	// Device flags
+	Flags []byte
+	// MCU firmware version
+	MCUVersion string
+}
+
+// Model Detect device model by target id, nil if the model is not known
+func (info *DeviceInfo) Model() *DeviceModel {
+	for i := range DeviceModels {
+		if info.TargetID&0xffff0000 == DeviceModels[i].TargetID {
+			return &DeviceModels[i]
+		}
+	}
+	return nil
+}
+
+// Decode GetDeviceInfo response: target id, SE version, flags and MCU version with length prefixes
+func parseDeviceInfo(data []byte) (*DeviceInfo, error) {
+	if len(data) < 4 {
+		return nil, fmt.Errorf("Wrong device info length: expected at least 4, got %v", len(data))
+	}
+	fields := make([][]byte, 0, 3)
+	offset := 4
+	for offset < len(data) && len(fields) < 3 {
+		size := int(data[offset])
+		if offset+1+size > len(data) {
+			return nil, fmt.Errorf("Device info is truncated")
+		}
+		fields = append(fields, data[offset+1:offset+1+size])
+		offset += 1 + size
+	}
+	if len(fields) < 3 {
+		return nil, fmt.Errorf("Device info is truncated")
+	}
+	return &DeviceInfo{
+		TargetID:   binary.BigEndian.Uint32(data),
+		SEVersion:  string(fields[0]),
+		Flags:      append([]byte{}, fields[1]...),
+		MCUVersion: string(fields[2]),
+	}, nil
+}
+
+// Encode GetDeviceInfo response
+func encodeDeviceInfo(info *DeviceInfo) []byte {
+	data := make([]byte, 4)
+	binary.BigEndian.PutUint32(data, info.TargetID)
+	for _, field := range [][]byte{[]byte(info.SEVersion), info.Flags, []byte(info.MCUVersion)} {
+		data = append(data, byte(len(field)))
+		data = append(data, field...)
+	}
+	return data
+}
+
+// GetDeviceInfo Get target id and firmware versions, the dashboard must be shown.
+//
+// return {*DeviceInfo} Firmware information.
+// return {error} Error value, ErrAppNotOpen if an app is running.
+//
+// example
+// info, err := device.GetDeviceInfo()
+//
+//	if err == nil {
+//		if model := info.Model(); model != nil {
+//			fmt.Printf("%v, firmware %v\n", model.Name, info.SEVersion)
+//		}
+//	}
+func (device *Ledger) GetDeviceInfo() (*DeviceInfo, error) {
+	return device.GetDeviceInfoContext(context.Background())
+}
+
+// GetDeviceInfoContext Same as GetDeviceInfo with the context
+func (device *Ledger) GetDeviceInfoContext(ctx context.Context) (*DeviceInfo, error) {
+	response, err := device.send(ctx, cCLADashboard, cInsGetDeviceInfo, 0, 0, nil)
+	if err != nil {
+		return nil, err
+	}
+	return parseDeviceInfo(response)
+}
@@ -0,0 +1,63 @@
+package ledger
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestDeviceModel(t *testing.T) {
+	tests := []struct {
+		productID uint16
+		model     string
+	}{
+		{0x0001, "Nano S"},
+		{0x1011, "Nano S"},
+		{0x0004, "Nano X"},
+		{0x4015, "Nano X"},
+		{0x5011, "Nano S Plus"},
+		{0x6011, "Stax"},
+	}
+	for _, test := range tests {
+		info := HidDeviceInfo{VendorID: LedgerUSBVendorID, ProductID: test.productID}
+		if model := info.Model(); model == nil || model.Name != test.model {
+			t.Errorf("%04x: WRONG model %+v\n", test.productID, model)
+		}
+	}
+	if model := (&HidDeviceInfo{ProductID: 0x9011}).Model(); model != nil {
+		t.Fatalf("WRONG model of unknown device %+v\n", model)
+	}
+	if model := (&DeviceInfo{TargetID: 0x33100004}).Model(); model == nil || model.ID != "nanoSP" {
+		t.Fatalf("WRONG model by target id %+v\n", model)
+	}
+}
+
+func TestGetDeviceInfo(t *testing.T) {
+	emulator := newTestEmulator(t)
+	device := NewLedger(emulator)
+	if model := device.GetHidInfo().Model(); model == nil || model.ID != "nanoS" {
+		t.Fatalf("WRONG emulator model %+v\n", model)
+	}
+
+	if _, err := device.GetDeviceInfo(); !errors.Is(err, ErrAppNotOpen) {
+		t.Fatalf("WRONG device info error in app: %v\n", err)
+	}
+	if err := device.QuitApp(); err != nil {
+		t.Fatalf("quit app ERROR: %v\n", err)
+	}
+	info, err := device.GetDeviceInfo()
+	if err != nil {
+		t.Fatalf("get device info ERROR: %v\n", err)
+	}
+	if info.TargetID != 0x31100004 || info.SEVersion != "2.1.0" || info.MCUVersion != "1.12" || len(info.Flags) != 4 {
+		t.Fatalf("WRONG device info %+v\n", info)
+	}
+	if model := info.Model(); model == nil || model.Name != "Nano S" {
+		t.Fatalf("WRONG model %+v\n", model)
+	}
+
+	for _, data := range [][]byte{{0x31, 0x10}, {0x31, 0x10, 0, 4, 1, '2'}, {0x31, 0x10, 0, 4, 0, 0}} {
+		if _, err := parseDeviceInfo(data); err == nil {
+			t.Errorf("%x: parse of invalid device info succeeded\n", data)
+		}
+	}
+}
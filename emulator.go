@@ -0,0 +1,371 @@
+package ledger
+
+import (
+	"bytes"
+	"crypto/sha512"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+
+	"github.com/spacemeshos/ed25519"
+)
+
+const (
+	// Max size of transaction accepted by the emulator
+	cEmulatorMaxTxSize = 64 * 1024
+	// Number of smidge in one SMH
+	cSmidgePerSMH = 1000000000000
+	// OS version reported in the dashboard
+	cEmulatorOSVersion = "2.1.0"
+	// MCU version reported in the dashboard
+	cEmulatorMCUVersion = "1.12"
+	// USB product id of emulated Nano S with HID and WebUSB interfaces
+	cEmulatorProductID = 0x1011
+	// Target id of emulated Nano S
+	cEmulatorTargetID = 0x31100004
+)
+
+// EmulatorScreen Single screen of the emulated device UI
+type EmulatorScreen struct {
+	// First line of the screen
+	Header string
+	// Second line of the screen
+	Text string
+	// Screen asks for confirmation: right button approves, left button rejects
+	Confirm bool
+}
+
+// EmulatorUser Emulated user reviewing actions on the device screen
+type EmulatorUser interface {
+	// Review walks the user through the action screens.
+	// Returns false if the user rejected the action.
+	Review(screens []EmulatorScreen) bool
+}
+
+// EmulatorUserFunc Adapter to use ordinary function as EmulatorUser
+type EmulatorUserFunc func(screens []EmulatorScreen) bool
+
+// Review calls f(screens)
+func (f EmulatorUserFunc) Review(screens []EmulatorScreen) bool {
+	return f(screens)
+}
+
+// Pending SignTx request
+type emulatorTx struct {
+	path BipPath
+	data []byte
+}
+
+// Emulator In-process emulator of the Spacemesh Ledger application.
+// It speaks the same APDU protocol as the device and signs with software keys
+// derived from the seed.
+type Emulator struct {
+	Info HidDeviceInfo
+	// Application version returned by GetVersion command
+	Version Version
+	// User confirming actions, nil approves everything
+	User EmulatorUser
+	// Dashboard is shown instead of the running Spacemesh app
+	Dashboard bool
+
+	seed   []byte
+	opened bool
+	tx     *emulatorTx
+	mutex  sync.Mutex
+}
+
+// NewEmulator Create new Emulator
+// param {[]byte} seed BIP39 seed used to derive the key tree.
+func NewEmulator(seed []byte) *Emulator {
+	return &Emulator{
+		Info: HidDeviceInfo{
+			Path:         "emulator",
+			VendorID:     LedgerUSBVendorID,
+			ProductID:    cEmulatorProductID,
+			SerialNumber: "0001",
+			Manufacturer: "Ledger",
+			Product:      "Nano S",
+		},
+		Version: Version{Major: 0, Minor: 0, Patch: 4},
+		seed:    append([]byte{}, seed...),
+	}
+}
+
+// Open Open emulated device
+func (device *Emulator) Open() error {
+	device.mutex.Lock()
+	defer device.mutex.Unlock()
+	device.opened = true
+	return nil
+}
+
+// Close Close emulated device
+func (device *Emulator) Close() {
+	device.mutex.Lock()
+	defer device.mutex.Unlock()
+	device.opened = false
+	device.tx = nil
+}
+
+// GetInfo Get emulated device info
+func (device *Emulator) GetInfo() *HidDeviceInfo {
+	return &device.Info
+}
+
+// Exchange Process APDU command and return response with status word.
+func (device *Emulator) Exchange(apdu []byte) ([]byte, error) {
+	device.mutex.Lock()
+	defer device.mutex.Unlock()
+
+	if !device.opened {
+		return nil, fmt.Errorf("Emulator is not opened")
+	}
+
+	data, status := device.process(apdu)
+	response := make([]byte, len(data)+2)
+	copy(response, data)
+	binary.BigEndian.PutUint16(response[len(data):], status)
+	return response, nil
+}
+
+// Dispatch APDU command
+func (device *Emulator) process(apdu []byte) ([]byte, uint16) {
+	if len(apdu) < 5 || len(apdu) != 5+int(apdu[4]) {
+		device.tx = nil
+		return nil, cSwMalformedRequest
+	}
+	cla, ins, p1, p2, data := apdu[0], apdu[1], apdu[2], apdu[3], apdu[5:]
+	if cla == cCLAOS || (device.Dashboard && cla == cCLADashboard) {
+		device.tx = nil
+		return device.processOS(cla, ins, p1, p2, data)
+	}
+	if cla != cCLA || device.Dashboard {
+		device.tx = nil
+		return nil, cSwAppNotLaunched
+	}
+	if ins != cInsSignTx {
+		device.tx = nil
+	}
+
+	switch ins {
+	case cInsGetVersion:
+		return device.getVersion(p1, p2, data)
+	case cInsGetExtPublicKey:
+		return device.getExtendedPublicKey(p1, p2, data)
+	case cInsGetAddress:
+		return device.getAddress(p1, p2, data)
+	case cInsSignTx:
+		return device.signTx(p1, p2, data)
+	default:
+		return nil, cSwUnknownIns
+	}
+}
+
+// Dispatch OS and dashboard command
+func (device *Emulator) processOS(cla, ins, p1, p2 byte, data []byte) ([]byte, uint16) {
+	if p1 != 0 || p2 != 0 {
+		return nil, cSwInvalidParameters
+	}
+	switch {
+	case cla == cCLADashboard && ins == cInsGetDeviceInfo:
+		return encodeDeviceInfo(&DeviceInfo{
+			TargetID:   cEmulatorTargetID,
+			SEVersion:  cEmulatorOSVersion,
+			Flags:      []byte{0, 0, 0, 0},
+			MCUVersion: cEmulatorMCUVersion,
+		}), cSwOK
+	case cla == cCLAOS && ins == cInsGetAppInfo:
+		info := &AppInfo{Name: DashboardAppName, Version: cEmulatorOSVersion}
+		if !device.Dashboard {
+			version := device.Version
+			info = &AppInfo{Name: SpacemeshAppName, Version: fmt.Sprintf("%v.%v.%v", version.Major, version.Minor, version.Patch), Flags: []byte{version.Flags}}
+		}
+		return encodeAppInfo(info), cSwOK
+	case cla == cCLAOS && ins == cInsQuitApp:
+		device.Dashboard = true
+		return nil, cSwOK
+	case cla == cCLADashboard && ins == cInsOpenApp:
+		if string(data) != SpacemeshAppName {
+			return nil, cSwAppNotInstalled
+		}
+		if !device.review([]EmulatorScreen{
+			{Header: "Open application", Text: SpacemeshAppName, Confirm: true},
+		}) {
+			return nil, cSwDeniedByUser
+		}
+		device.Dashboard = false
+		return nil, cSwOK
+	default:
+		return nil, cSwUnknownIns
+	}
+}
+
+// Ask the user to review the action
+func (device *Emulator) review(screens []EmulatorScreen) bool {
+	if device.User == nil {
+		return true
+	}
+	return device.User.Review(screens)
+}
+
+// Derive key tree node for the path received in request
+func (device *Emulator) derive(data []byte) (BipPath, *keyNode, uint16) {
+	path, size, err := bytesToPath(data)
+	if err != nil || size != len(data) {
+		return nil, nil, cSwInvalidData
+	}
+	if len(path) < 2 || path[0] != 44|Hardened || path[1] != 540|Hardened {
+		return nil, nil, cSwInvalidData
+	}
+	node, err := deriveNode(device.seed, path)
+	if err != nil {
+		return nil, nil, cSwInvalidData
+	}
+	return path, node, cSwOK
+}
+
+// Process GetVersion command
+func (device *Emulator) getVersion(p1, p2 byte, data []byte) ([]byte, uint16) {
+	if p1 != cP1Unused || p2 != cP2Unused || len(data) != 0 {
+		return nil, cSwInvalidParameters
+	}
+	version := device.Version
+	return []byte{version.Major, version.Minor, version.Patch, version.Flags}, cSwOK
+}
+
+// Process GetExtPublicKey command
+func (device *Emulator) getExtendedPublicKey(p1, p2 byte, data []byte) ([]byte, uint16) {
+	if p1 != cP1Unused || p2 != cP2Unused {
+		return nil, cSwInvalidParameters
+	}
+	path, node, status := device.derive(data)
+	if status != cSwOK {
+		return nil, status
+	}
+	if !device.review([]EmulatorScreen{
+		{Header: "Export public key", Text: path.String()},
+		{Header: "Confirm export", Text: "public key?", Confirm: true},
+	}) {
+		return nil, cSwRejected
+	}
+	return append(node.publicKey(), node.chainCode...), cSwOK
+}
+
+// Process GetAddress command
+func (device *Emulator) getAddress(p1, p2 byte, data []byte) ([]byte, uint16) {
+	if (p1 != cP1Return && p1 != cP1Display) || p2 != cP2Unused {
+		return nil, cSwInvalidParameters
+	}
+	path, node, status := device.derive(data)
+	if status != cSwOK {
+		return nil, status
+	}
+	address := node.publicKey()[:AddressSize]
+	if p1 == cP1Display {
+		if !device.review([]EmulatorScreen{
+			{Header: "Verify address", Text: "Make sure it agrees with your computer"},
+			{Header: "Address path", Text: path.String()},
+			{Header: "Address", Text: hex.EncodeToString(address)},
+		}) {
+			return nil, cSwRejected
+		}
+		return nil, cSwOK
+	}
+	if !device.review([]EmulatorScreen{
+		{Header: "Export address", Text: "Path: " + path.String()},
+		{Header: "Confirm", Text: "export address?", Confirm: true},
+	}) {
+		return nil, cSwRejected
+	}
+	return address, cSwOK
+}
+
+// Process SignTx command
+func (device *Emulator) signTx(p1, p2 byte, data []byte) ([]byte, uint16) {
+	if p2 != cP2Unused {
+		device.tx = nil
+		return nil, cSwInvalidParameters
+	}
+
+	switch p1 {
+	case cP1HasHeader | cP1IsLast, cP1HasHeader | cP1HasData:
+		path, size, err := bytesToPath(data)
+		if err != nil {
+			device.tx = nil
+			return nil, cSwInvalidData
+		}
+		device.tx = &emulatorTx{path: path, data: append([]byte{}, data[size:]...)}
+	case cP1HasData, cP1IsLast:
+		if device.tx == nil {
+			return nil, cSwInvalidState
+		}
+		device.tx.data = append(device.tx.data, data...)
+	default:
+		device.tx = nil
+		return nil, cSwInvalidParameters
+	}
+
+	if len(device.tx.data) > cEmulatorMaxTxSize {
+		device.tx = nil
+		return nil, cSwInvalidData
+	}
+	if p1&cP1IsLast == 0 {
+		return nil, cSwOK
+	}
+
+	tx := device.tx
+	device.tx = nil
+	return device.sign(tx)
+}
+
+// Validate, show and sign the complete transaction
+func (device *Emulator) sign(tx *emulatorTx) ([]byte, uint16) {
+	_, node, status := device.derive(pathToBytes(tx.path))
+	if status != cSwOK {
+		return nil, status
+	}
+	decoded, err := DecodeTx(tx.data)
+	if err != nil {
+		return nil, cSwInvalidData
+	}
+	header := decoded.Header()
+	publicKey := node.publicKey()
+	if !header.EdPlus && !bytes.Equal(header.PublicKey, publicKey) {
+		return nil, cSwInvalidData
+	}
+	fee := new(big.Int).Mul(new(big.Int).SetUint64(header.GasLimit), new(big.Int).SetUint64(header.GasPrice))
+
+	if !device.review([]EmulatorScreen{
+		{Header: "Tx type:", Text: decoded.Type().String()},
+		{Header: "Send SMH", Text: formatSMH(new(big.Int).SetUint64(header.Amount))},
+		{Header: "To address", Text: hex.EncodeToString(header.Recipient)},
+		{Header: "Max Tx Fee", Text: formatSMH(fee)},
+		{Header: "Confirm", Text: "transaction?", Confirm: true},
+		{Header: "Signer", Text: hex.EncodeToString(publicKey[:AddressSize])},
+		{Header: "Sign using", Text: "this signer?", Confirm: true},
+	}) {
+		return nil, cSwRejected
+	}
+
+	hash := sha512.Sum512(tx.data)
+	var signature []byte
+	if header.EdPlus {
+		signature = ed25519.Sign2(node.privateKey(), hash[:])
+	} else {
+		signature = ed25519.Sign(node.privateKey(), hash[:])
+	}
+	return append(signature, publicKey...), cSwOK
+}
+
+// Format amount of smidge as SMH, e.g. 1.0 or 0.001
+func formatSMH(smidge *big.Int) string {
+	integer, fraction := new(big.Int).QuoRem(smidge, big.NewInt(cSmidgePerSMH), new(big.Int))
+	fractionStr := strings.TrimRight(fmt.Sprintf("%012d", fraction), "0")
+	if fractionStr == "" {
+		fractionStr = "0"
+	}
+	return integer.String() + "." + fractionStr
+}
@@ -0,0 +1,173 @@
+package ledger
+
+import (
+	"encoding/hex"
+	"errors"
+	"testing"
+)
+
+// BIP39 seed of the "secret" mnemonic used by Speculos in docker-compose.yml
+const testSeed = "19a2b554bec0dacb8b281da19dda5548c6ec0ab35dc964496eff4b267a70ae2370e659d462415a9e3a61801371a5bd57ef48370f93c018a5d4e348c693dad76a"
+
+// Public key of "44'/540'/0'/0/0'" path for the test seed
+const testPublicKey = "a47a88814cecde42f2ad0d75123cf530fbe8e5940bbc44273014714df9a33e16"
+
+// Create opened emulator with the test seed
+func newTestEmulator(t *testing.T) *Emulator {
+	seed, err := hex.DecodeString(testSeed)
+	if err != nil {
+		t.Fatalf("decode seed ERROR: %v\n", err)
+	}
+	emulator := NewEmulator(seed)
+	if err := emulator.Open(); err != nil {
+		t.Fatalf("open emulator ERROR: %v\n", err)
+	}
+	return emulator
+}
+
+// Exchange raw APDU with emulator and return the status word
+func exchangeStatus(t *testing.T, emulator *Emulator, apdu []byte) uint32 {
+	response, err := emulator.Exchange(apdu)
+	if err != nil {
+		t.Fatalf("exchange ERROR: %v\n", err)
+	}
+	_, status := stripRetcodeFromResponse(response)
+	return status
+}
+
+func TestEmulator(t *testing.T) {
+	emulator := newTestEmulator(t)
+	device := NewLedger(emulator)
+	path := StringToPath("44'/540'/0'/0/0'")
+
+	version, err := device.GetVersion()
+	if err != nil {
+		t.Fatalf("get version ERROR: %v\n", err)
+	}
+	if *version != emulator.Version {
+		t.Fatalf("WRONG version: %+v\n", version)
+	}
+
+	publicKey, err := device.GetExtendedPublicKey(path)
+	if err != nil {
+		t.Fatalf("get public key ERROR: %v\n", err)
+	}
+	if key := hex.EncodeToString(publicKey.PublicKey); key != testPublicKey {
+		t.Fatalf("WRONG public key: %v\n", key)
+	}
+
+	address, err := device.GetAddress(path)
+	if err != nil {
+		t.Fatalf("get address ERROR: %v\n", err)
+	}
+	if addressStr := hex.EncodeToString(address); addressStr != testPublicKey[:40] {
+		t.Fatalf("WRONG address: %v\n", addressStr)
+	}
+
+	if err := device.ShowAddress(path); err != nil {
+		t.Fatalf("show address ERROR: %v\n", err)
+	}
+
+	if !testTx(t, device, "coin.tx.json", "coin", publicKey.PublicKey, nil) {
+		t.FailNow()
+	}
+	if !testTx(t, device, "app.tx.json", "app", publicKey.PublicKey, nil) {
+		t.FailNow()
+	}
+	if !testTx(t, device, "spawn.tx.json", "spawn", publicKey.PublicKey, nil) {
+		t.FailNow()
+	}
+}
+
+func TestEmulatorScreens(t *testing.T) {
+	emulator := newTestEmulator(t)
+	device := NewLedger(emulator)
+	path := StringToPath("44'/540'/0'/0/0'")
+
+	var screens []EmulatorScreen
+	emulator.User = EmulatorUserFunc(func(s []EmulatorScreen) bool {
+		screens = s
+		return true
+	})
+	publicKey, err := device.GetExtendedPublicKey(path)
+	if err != nil {
+		t.Fatalf("get public key ERROR: %v\n", err)
+	}
+	if len(screens) != 2 || screens[0].Text != "m/44'/540'/0'/0/0'" || !screens[1].Confirm {
+		t.Fatalf("WRONG public key screens: %+v\n", screens)
+	}
+
+	if !testTx(t, device, "coin.tx.json", "coin", publicKey.PublicKey, nil) {
+		t.FailNow()
+	}
+	expected := []string{"COIN ED", "1.0", testPublicKey[:40], "0.001", "transaction?", testPublicKey[:40], "this signer?"}
+	if len(screens) != len(expected) {
+		t.Fatalf("WRONG tx screens: %+v\n", screens)
+	}
+	for i, text := range expected {
+		if screens[i].Text != text {
+			t.Fatalf("WRONG tx screen %v: %+v\n", i, screens[i])
+		}
+	}
+}
+
+func TestEmulatorReject(t *testing.T) {
+	emulator := newTestEmulator(t)
+	emulator.User = EmulatorUserFunc(func(screens []EmulatorScreen) bool {
+		return false
+	})
+	device := NewLedger(emulator)
+
+	_, err := device.GetExtendedPublicKey(StringToPath("44'/540'/0'/0/0'"))
+	if err == nil || err.Error() != "Request Error 0x6E09: User rejected the action" {
+		t.Fatalf("WRONG reject error: %v\n", err)
+	}
+	if err := device.ShowAddress(StringToPath("44'/540'/0'/0/0'")); !errors.Is(err, ErrUserRejected) {
+		t.Fatalf("WRONG show address reject error: %v\n", err)
+	}
+}
+
+func TestEmulatorErrors(t *testing.T) {
+	emulator := newTestEmulator(t)
+	path := pathToBytes(StringToPath("44'/540'/0'/0/0'"))
+
+	tests := []struct {
+		name   string
+		apdu   []byte
+		status uint32
+	}{
+		{"wrong CLA", []byte{0xe0, cInsGetVersion, 0, 0, 0}, cSwAppNotLaunched},
+		{"wrong length", []byte{cCLA, cInsGetVersion, 0, 0, 1}, cSwMalformedRequest},
+		{"unknown INS", []byte{cCLA, 0x99, 0, 0, 0}, cSwUnknownIns},
+		{"GetVersion P1", []byte{cCLA, cInsGetVersion, 1, 0, 0}, cSwInvalidParameters},
+		{"GetAddress P1", append([]byte{cCLA, cInsGetAddress, 0, 0, byte(len(path))}, path...), cSwInvalidParameters},
+		{"wrong coin type", append([]byte{cCLA, cInsGetExtPublicKey, 0, 0, 9}, pathToBytes(StringToPath("44'/60'"))...), cSwInvalidData},
+		{"truncated path", []byte{cCLA, cInsGetExtPublicKey, 0, 0, 3, 2, 0x80, 0}, cSwInvalidData},
+		{"SignTx P1", append([]byte{cCLA, cInsSignTx, cP1HasHeader, 0, byte(len(path))}, path...), cSwInvalidParameters},
+		{"SignTx without header", []byte{cCLA, cInsSignTx, cP1HasData, 0, 1, 0}, cSwInvalidState},
+		{"SignTx last without header", []byte{cCLA, cInsSignTx, cP1IsLast, 0, 1, 0}, cSwInvalidState},
+		{"SignTx short tx", append([]byte{cCLA, cInsSignTx, cP1HasHeader | cP1IsLast, 0, byte(len(path))}, path...), cSwInvalidData},
+	}
+	for _, test := range tests {
+		if status := exchangeStatus(t, emulator, test.apdu); status != test.status {
+			t.Errorf("%v: expected status %x, got %x\n", test.name, test.status, status)
+		}
+	}
+
+	// Request of other command breaks SignTx sequence
+	start := append([]byte{cCLA, cInsSignTx, cP1HasHeader | cP1HasData, 0, byte(len(path))}, path...)
+	if status := exchangeStatus(t, emulator, start); status != cSwOK {
+		t.Fatalf("SignTx start: expected status %x, got %x\n", cSwOK, status)
+	}
+	if status := exchangeStatus(t, emulator, []byte{cCLA, cInsGetVersion, 0, 0, 0}); status != cSwOK {
+		t.Fatalf("GetVersion: expected status %x, got %x\n", cSwOK, status)
+	}
+	if status := exchangeStatus(t, emulator, []byte{cCLA, cInsSignTx, cP1IsLast, 0, 1, 0}); status != cSwInvalidState {
+		t.Fatalf("SignTx after GetVersion: expected status %x, got %x\n", cSwInvalidState, status)
+	}
+
+	emulator.Close()
+	if _, err := emulator.Exchange([]byte{cCLA, cInsGetVersion, 0, 0, 0}); err == nil {
+		t.Fatalf("exchange with closed emulator should fail\n")
+	}
+}
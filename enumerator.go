@@ -0,0 +1,232 @@
+package ledger
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+var (
+	// ErrNoDevice No connected device matches the filter
+	ErrNoDevice = errors.New("No matching Ledger device found")
+	// ErrMultipleDevices More than one connected device matches the filter
+	ErrMultipleDevices = errors.New("Multiple matching Ledger devices found")
+	// ErrNoHidBackend The package is built without HID backend, see the nohid build tag
+	ErrNoHidBackend = errors.New("HID backend is not available in this build")
+)
+
+// Enumerator Source of connected Ledger devices
+type Enumerator interface {
+	// Enumerate List connected devices, the devices are not opened
+	Enumerate() ([]*Ledger, error)
+}
+
+// EnumeratorFunc Adapter to use ordinary function as Enumerator
+type EnumeratorFunc func() ([]*Ledger, error)
+
+// Enumerate calls f()
+func (f EnumeratorFunc) Enumerate() ([]*Ledger, error) {
+	return f()
+}
+
+// HidEnumerator Enumerate USB HID devices, see GetDevices
+type HidEnumerator struct {
+	// USB Product ID filter, 0 - all
+	ProductID int
+}
+
+// Enumerate List connected Ledger devices, returns ErrNoHidBackend if the package is built without HID backend
+func (enumerator *HidEnumerator) Enumerate() ([]*Ledger, error) {
+	if !hidAvailable {
+		return nil, ErrNoHidBackend
+	}
+	return GetDevices(enumerator.ProductID), nil
+}
+
+// DeviceFilter Criteria of device selection, empty fields match any device
+type DeviceFilter struct {
+	// Device serial number
+	SerialNumber string
+	// Model ID or name, e.g. "nanoX" or "Nano X"
+	Model string
+	// Platform-specific device path
+	Path string
+	// Public key the device derives at PublicKeyPath,
+	// the user may have to confirm the export on every connected device.
+	// Devices opened with Ledger.Open stay open, others are opened for the request and closed.
+	PublicKey []byte
+	// Path of the public key
+	PublicKeyPath BipPath
+}
+
+// Reports whether the device at the new path may be the same physical device re-enumerated,
+// e.g. with another product id when an app is opened. Ledger devices of the same model report
+// the same serial number, so the serial only correlates a single disappeared device.
+func maybeSameDevice(previous *HidDeviceInfo, info *HidDeviceInfo) bool {
+	if previous.SerialNumber == "" || previous.SerialNumber != info.SerialNumber || previous.VendorID != info.VendorID {
+		return false
+	}
+	previousModel, model := previous.Model(), info.Model()
+	return previousModel != nil && model != nil && previousModel.ID == model.ID
+}
+
+// Check filter criteria available without opening the device
+func (filter *DeviceFilter) matchInfo(info *HidDeviceInfo) bool {
+	if filter.SerialNumber != "" && info.SerialNumber != filter.SerialNumber {
+		return false
+	}
+	if filter.Path != "" && info.Path != filter.Path {
+		return false
+	}
+	if filter.Model != "" {
+		model := info.Model()
+		if model == nil || (model.ID != filter.Model && model.Name != filter.Model) {
+			return false
+		}
+	}
+	return true
+}
+
+// Check public key of the device, the device is opened for the request unless it is open
+func (filter *DeviceFilter) matchPublicKey(ctx context.Context, device *Ledger) (bool, error) {
+	if !device.opened {
+		if err := device.Open(); err != nil {
+			return false, err
+		}
+		defer device.Close()
+	}
+	publicKey, err := device.GetExtendedPublicKeyContext(ctx, filter.PublicKeyPath)
+	if err != nil {
+		return false, err
+	}
+	return bytes.Equal(publicKey.PublicKey, filter.PublicKey), nil
+}
+
+// Sort devices by path and serial number
+func sortDevices(devices []*Ledger) {
+	sort.SliceStable(devices, func(i, j int) bool {
+		a, b := devices[i].GetHidInfo(), devices[j].GetHidInfo()
+		if a.Path != b.Path {
+			return a.Path < b.Path
+		}
+		return a.SerialNumber < b.SerialNumber
+	})
+}
+
+// DeviceErrors Errors of the devices skipped by FindDevices, e.g. locked devices or devices
+// running another app when the filter has PublicKey. Returned only if no device matches.
+type DeviceErrors []error
+
+// Error Error message
+func (e DeviceErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+	return fmt.Sprintf("%v: %v", ErrNoDevice, strings.Join(messages, "; "))
+}
+
+// Is Reports whether the target is ErrNoDevice or any of the device errors matches it
+func (e DeviceErrors) Is(target error) bool {
+	if target == ErrNoDevice {
+		return true
+	}
+	for _, err := range e {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// As Finds the first device error matching the target
+func (e DeviceErrors) As(target interface{}) bool {
+	for _, err := range e {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// FindDevices List devices matching the filter sorted by path and serial number.
+// The returned devices are not opened by this function.
+// A device failing the public key request does not match.
+//
+// param {context.Context} ctx Context of the public key requests.
+// param {Enumerator} enumerator Source of devices, nil for HidEnumerator.
+// param {DeviceFilter} filter Selection criteria.
+// return {[]*Ledger} Matching devices.
+// return {error} Error value, DeviceErrors if no device matches and some devices failed,
+// CanceledError when the context is done.
+func FindDevices(ctx context.Context, enumerator Enumerator, filter DeviceFilter) ([]*Ledger, error) {
+	if enumerator == nil {
+		enumerator = &HidEnumerator{}
+	}
+	devices, err := enumerator.Enumerate()
+	if err != nil {
+		return nil, err
+	}
+	sortDevices(devices)
+
+	result := make([]*Ledger, 0, len(devices))
+	var deviceErrors DeviceErrors
+	for _, device := range devices {
+		if !filter.matchInfo(device.GetHidInfo()) {
+			continue
+		}
+		if filter.PublicKey != nil {
+			match, err := filter.matchPublicKey(ctx, device)
+			var canceled *CanceledError
+			if errors.As(err, &canceled) {
+				return nil, err
+			}
+			if err != nil {
+				deviceErrors = append(deviceErrors, fmt.Errorf("Device %v: %w", device.GetHidInfo().Path, err))
+				continue
+			}
+			if !match {
+				continue
+			}
+		}
+		device.enumerator = enumerator
+		result = append(result, device)
+	}
+	if len(result) == 0 && len(deviceErrors) > 0 {
+		return nil, deviceErrors
+	}
+	return result, nil
+}
+
+// SelectDevice Find exactly one device matching the filter, the device is not opened.
+//
+// param {context.Context} ctx Context of the public key requests.
+// param {Enumerator} enumerator Source of devices, nil for HidEnumerator.
+// param {DeviceFilter} filter Selection criteria.
+// return {*Ledger} The device.
+// return {error} Error value, ErrNoDevice or ErrMultipleDevices if there is not exactly one matching device,
+// DeviceErrors matching ErrNoDevice if no device matches and some devices failed.
+//
+// example
+// device, err := ledger.SelectDevice(ctx, nil, ledger.DeviceFilter{SerialNumber: serial})
+//
+//	if err == nil {
+//		err = device.Open()
+//	}
+func SelectDevice(ctx context.Context, enumerator Enumerator, filter DeviceFilter) (*Ledger, error) {
+	devices, err := FindDevices(ctx, enumerator, filter)
+	if err != nil {
+		return nil, err
+	}
+	switch len(devices) {
+	case 0:
+		return nil, ErrNoDevice
+	case 1:
+		return devices[0], nil
+	default:
+		return nil, ErrMultipleDevices
+	}
+}
@@ -0,0 +1,124 @@
+package ledger
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// Enumerator of emulated devices with different seeds and models
+func newTestEnumerator(t *testing.T) Enumerator {
+	nanoX := NewEmulator(MnemonicToSeed("other", ""))
+	nanoX.Info = HidDeviceInfo{Path: "b", VendorID: LedgerUSBVendorID, ProductID: 0x4011, SerialNumber: "0002"}
+	nanoS := newTestEmulator(t)
+	nanoS.Info.Path = "c"
+	nanoSPlus := NewEmulator(MnemonicToSeed("secret", ""))
+	nanoSPlus.Info = HidDeviceInfo{Path: "a", VendorID: LedgerUSBVendorID, ProductID: 0x5011, SerialNumber: "0003"}
+	return EnumeratorFunc(func() ([]*Ledger, error) {
+		return []*Ledger{NewLedger(nanoX), NewLedger(nanoS), NewLedger(nanoSPlus)}, nil
+	})
+}
+
+func TestFindDevices(t *testing.T) {
+	ctx := context.Background()
+	enumerator := newTestEnumerator(t)
+
+	devices, err := FindDevices(ctx, enumerator, DeviceFilter{})
+	if err != nil || len(devices) != 3 {
+		t.Fatalf("find devices ERROR: %v, %v\n", err, devices)
+	}
+	for i, path := range []string{"a", "b", "c"} {
+		if devices[i].GetHidInfo().Path != path {
+			t.Fatalf("WRONG order of devices: %v at %v\n", devices[i].GetHidInfo().Path, i)
+		}
+	}
+
+	tests := []struct {
+		filter DeviceFilter
+		path   string
+	}{
+		{DeviceFilter{SerialNumber: "0002"}, "b"},
+		{DeviceFilter{Model: "nanoS"}, "c"},
+		{DeviceFilter{Model: "Nano S Plus"}, "a"},
+		{DeviceFilter{Path: "c", Model: "Nano S"}, "c"},
+	}
+	for _, test := range tests {
+		device, err := SelectDevice(ctx, enumerator, test.filter)
+		if err != nil || device.GetHidInfo().Path != test.path {
+			t.Fatalf("%+v: select device ERROR: %v\n", test.filter, err)
+		}
+	}
+
+	if _, err := SelectDevice(ctx, enumerator, DeviceFilter{Model: "stax"}); !errors.Is(err, ErrNoDevice) {
+		t.Fatalf("WRONG no device error: %v\n", err)
+	}
+	if _, err := SelectDevice(ctx, enumerator, DeviceFilter{}); !errors.Is(err, ErrMultipleDevices) {
+		t.Fatalf("WRONG multiple devices error: %v\n", err)
+	}
+}
+
+func TestFindDevicesByPublicKey(t *testing.T) {
+	ctx := context.Background()
+	enumerator := newTestEnumerator(t)
+	publicKey, err := DeriveExtendedPublicKey(MnemonicToSeed("other", ""), SpacemeshPath(0, 0, 0))
+	if err != nil {
+		t.Fatalf("derive public key ERROR: %v\n", err)
+	}
+
+	device, err := SelectDevice(ctx, enumerator, DeviceFilter{PublicKey: publicKey.PublicKey, PublicKeyPath: SpacemeshPath(0, 0, 0)})
+	if err != nil || device.GetHidInfo().SerialNumber != "0002" {
+		t.Fatalf("select device by public key ERROR: %v\n", err)
+	}
+
+	// Two devices share the seed
+	publicKey, _ = DeriveExtendedPublicKey(MnemonicToSeed("secret", ""), SpacemeshPath(0, 0, 0))
+	devices, err := FindDevices(ctx, enumerator, DeviceFilter{PublicKey: publicKey.PublicKey, PublicKeyPath: SpacemeshPath(0, 0, 0)})
+	if err != nil || len(devices) != 2 {
+		t.Fatalf("find devices by public key ERROR: %v, %v\n", err, devices)
+	}
+
+	if _, err := FindDevices(ctx, enumerator, DeviceFilter{PublicKey: publicKey.PublicKey, PublicKeyPath: StringToPath("44'/60'")}); err == nil {
+		t.Fatalf("find devices with invalid path succeeded\n")
+	}
+}
+
+func TestFindDevicesSkipsFailingDevices(t *testing.T) {
+	ctx := context.Background()
+	// Device running the dashboard and device of the user rejecting the export
+	dashboard := NewEmulator(MnemonicToSeed("other", ""))
+	dashboard.Info.Path = "a"
+	dashboard.Dashboard = true
+	rejecting := NewEmulator(MnemonicToSeed("other", ""))
+	rejecting.Info.Path = "b"
+	rejecting.User = EmulatorUserFunc(func(screens []EmulatorScreen) bool { return false })
+	opened := NewLedger(newTestEmulator(t))
+	if err := opened.Open(); err != nil {
+		t.Fatalf("open device ERROR: %v\n", err)
+	}
+	defer opened.Close()
+	enumerator := EnumeratorFunc(func() ([]*Ledger, error) {
+		return []*Ledger{NewLedger(dashboard), NewLedger(rejecting), opened}, nil
+	})
+
+	publicKey, err := opened.GetExtendedPublicKey(SpacemeshPath(0, 0, 0))
+	if err != nil {
+		t.Fatalf("get public key ERROR: %v\n", err)
+	}
+	device, err := SelectDevice(ctx, enumerator, DeviceFilter{PublicKey: publicKey.PublicKey, PublicKeyPath: SpacemeshPath(0, 0, 0)})
+	if err != nil || device != opened {
+		t.Fatalf("select device with failing devices ERROR: %v\n", err)
+	}
+	if _, err := opened.GetVersion(); err != nil {
+		t.Fatalf("WRONG device closed by the search: %v\n", err)
+	}
+
+	publicKey, _ = DeriveExtendedPublicKey(MnemonicToSeed("other", ""), SpacemeshPath(0, 0, 0))
+	_, err = FindDevices(ctx, enumerator, DeviceFilter{PublicKey: publicKey.PublicKey, PublicKeyPath: SpacemeshPath(0, 0, 0)})
+	var deviceErrors DeviceErrors
+	if !errors.As(err, &deviceErrors) || len(deviceErrors) != 2 {
+		t.Fatalf("WRONG device errors: %v\n", err)
+	}
+	if !errors.Is(err, ErrNoDevice) || !errors.Is(err, ErrUserRejected) {
+		t.Fatalf("WRONG device errors: %v\n", err)
+	}
+}
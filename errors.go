@@ -0,0 +1,181 @@
+package ledger
+
+import (
+	"errors"
+	"fmt"
+)
+
+const (
+	// Status word: request processed successfully
+	cSwOK = 0x9000
+	// Status word: application is not launched (CLA is not supported)
+	cSwAppNotLaunched = 0x6E00
+	// Status word: malformed request header
+	cSwMalformedRequest = 0x6E01
+	// Status word: unknown instruction
+	cSwUnknownIns = 0x6E03
+	// Status word: another request is in progress
+	cSwStillInCall = 0x6E04
+	// Status word: P1, P2 or payload is invalid
+	cSwInvalidParameters = 0x6E05
+	// Status word: request is not valid in the context of previous calls
+	cSwInvalidState = 0x6E06
+	// Status word: some part of request data is invalid
+	cSwInvalidData = 0x6E07
+	// Status word: user rejected the action
+	cSwRejected = 0x6E09
+	// Status word: action is rejected by the application policy
+	cSwRejectedByPolicy = 0x6E10
+	// Status word: pin screen
+	cSwDeviceLocked = 0x6E11
+	// Status word: device is locked, reported by the OS on newer firmware
+	cSwDeviceLockedOS = 0x5515
+	// Status word: dashboard did not find the app to open
+	cSwAppNotInstalled = 0x6807
+	// Status word: dashboard conditions of use not satisfied, the user denied the action
+	cSwDeniedByUser = 0x6985
+)
+
+// Error messages of the known status words
+var statusMessages = map[uint16]string{
+	cSwAppNotLaunched:    "Spacemesh app is not launched",
+	cSwMalformedRequest:  "Request Error 0x6E01: Malformed request header",
+	cSwUnknownIns:        "Request Error 0x6E03: Unknown instruction",
+	cSwStillInCall:       "Request Error 0x6E04: Another request is in progress",
+	cSwInvalidParameters: "Request Error 0x6E05: P1, P2 or payload is invalid",
+	cSwInvalidState:      "Request Error 0x6E06: Request is not valid in the context of previous calls",
+	cSwInvalidData:       "Request Error 0x6E07: Some part of request data is invalid",
+	cSwRejected:          "Request Error 0x6E09: User rejected the action",
+	cSwRejectedByPolicy:  "Request Error 0x6E10: Action is rejected by policy",
+	cSwDeviceLocked:      "Request Error 0x6E11: Pin screen",
+	cSwDeviceLockedOS:    "Request Error 0x5515: Device is locked",
+	cSwAppNotInstalled:   "Request Error 0x6807: App is not installed",
+	cSwDeniedByUser:      "Request Error 0x6985: User denied the action",
+}
+
+var (
+	// ErrAppNotOpen Spacemesh app is not launched
+	ErrAppNotOpen = &StatusError{SW: cSwAppNotLaunched}
+	// ErrUnknownInstruction The app does not support the instruction
+	ErrUnknownInstruction = &StatusError{SW: cSwUnknownIns}
+	// ErrStillInCall Another request is in progress
+	ErrStillInCall = &StatusError{SW: cSwStillInCall}
+	// ErrInvalidParameters P1, P2 or payload is invalid
+	ErrInvalidParameters = &StatusError{SW: cSwInvalidParameters}
+	// ErrInvalidState Request is not valid in the context of previous calls
+	ErrInvalidState = &StatusError{SW: cSwInvalidState}
+	// ErrInvalidData Some part of request data is invalid
+	ErrInvalidData = &StatusError{SW: cSwInvalidData}
+	// ErrUserRejected User rejected the action on the device
+	ErrUserRejected = &StatusError{SW: cSwRejected}
+	// ErrRejectedByPolicy Action is rejected by the app policy
+	ErrRejectedByPolicy = &StatusError{SW: cSwRejectedByPolicy}
+	// ErrDeviceLocked Device is locked, the pin screen is shown
+	ErrDeviceLocked = &StatusError{SW: cSwDeviceLocked}
+	// ErrAppNotInstalled The app to open is not installed on the device
+	ErrAppNotInstalled = &StatusError{SW: cSwAppNotInstalled}
+)
+
+// StatusError Device completed the request with error status word
+type StatusError struct {
+	// Status word
+	SW uint16
+	// Response data without status word
+	Response []byte
+}
+
+// Error Error message
+func (e *StatusError) Error() string {
+	if message, ok := statusMessages[e.SW]; ok {
+		return message
+	}
+	return fmt.Sprintf("Request Error: %x", e.SW)
+}
+
+// Is Reports whether the target is StatusError with the same status word,
+// both pin screen status words match ErrDeviceLocked and denial in the dashboard
+// matches ErrUserRejected.
+func (e *StatusError) Is(target error) bool {
+	t, ok := target.(*StatusError)
+	if !ok {
+		return false
+	}
+	if t.SW == cSwDeviceLocked && e.SW == cSwDeviceLockedOS {
+		return true
+	}
+	if t.SW == cSwRejected && e.SW == cSwDeniedByUser {
+		return true
+	}
+	return t.SW == e.SW
+}
+
+// Retryable Reports whether the same request may succeed after the user
+// unlocks the device, opens the app or completes the pending request.
+func (e *StatusError) Retryable() bool {
+	switch e.SW {
+	case cSwAppNotLaunched, cSwStillInCall, cSwDeviceLocked, cSwDeviceLockedOS:
+		return true
+	default:
+		return false
+	}
+}
+
+// IsRetryable Reports whether err is StatusError that may go away when the request is repeated
+func IsRetryable(err error) bool {
+	var statusErr *StatusError
+	return errors.As(err, &statusErr) && statusErr.Retryable()
+}
+
+// IsTerminal Reports whether err is StatusError that repeating the same request will not fix,
+// e.g. the user rejected the action or the request data is invalid.
+func IsTerminal(err error) bool {
+	var statusErr *StatusError
+	return errors.As(err, &statusErr) && !statusErr.Retryable()
+}
+
+// CanceledError Request was aborted because the context is done
+type CanceledError struct {
+	// Context error
+	Err error
+}
+
+// Error Error message
+func (e *CanceledError) Error() string {
+	return "Request canceled: " + e.Err.Error()
+}
+
+// Unwrap Returns the context error
+func (e *CanceledError) Unwrap() error {
+	return e.Err
+}
+
+var (
+	// ErrInvalidSignature Signature returned by the device does not verify
+	ErrInvalidSignature = errors.New("Invalid signature")
+	// ErrPublicKeyMismatch Public key returned by the device differs from the expected one
+	ErrPublicKeyMismatch = errors.New("Public key mismatch")
+)
+
+// VerificationError Signature returned by SignTx failed host-side verification
+type VerificationError struct {
+	// Path of the signing key
+	Path BipPath
+	// Type of the signed transaction
+	Type TxType
+	// Public key returned by the device
+	PublicKey []byte
+	// Public key the device was expected to return, for ErrPublicKeyMismatch only
+	ExpectedPublicKey []byte
+	// ErrInvalidSignature or ErrPublicKeyMismatch
+	Err error
+}
+
+// Error Error message
+func (e *VerificationError) Error() string {
+	return fmt.Sprintf("Signature verification failed for %v: %v", e.Path, e.Err)
+}
+
+// Unwrap Returns ErrInvalidSignature or ErrPublicKeyMismatch
+func (e *VerificationError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,93 @@
+package ledger
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+// Device returning the same response to every request
+type staticDevice struct {
+	Info     HidDeviceInfo
+	response []byte
+}
+
+func (device *staticDevice) Open() error {
+	return nil
+}
+
+func (device *staticDevice) Close() {
+}
+
+func (device *staticDevice) GetInfo() *HidDeviceInfo {
+	return &device.Info
+}
+
+func (device *staticDevice) Exchange(apdu []byte) ([]byte, error) {
+	return device.response, nil
+}
+
+func TestStatusError(t *testing.T) {
+	tests := []struct {
+		response  []byte
+		target    error
+		message   string
+		retryable bool
+	}{
+		{[]byte{0x6e, 0x00}, ErrAppNotOpen, "Spacemesh app is not launched", true},
+		{[]byte{0x6e, 0x04}, ErrStillInCall, "Request Error 0x6E04: Another request is in progress", true},
+		{[]byte{0x6e, 0x05}, ErrInvalidParameters, "Request Error 0x6E05: P1, P2 or payload is invalid", false},
+		{[]byte{0x6e, 0x06}, ErrInvalidState, "Request Error 0x6E06: Request is not valid in the context of previous calls", false},
+		{[]byte{0x6e, 0x07}, ErrInvalidData, "Request Error 0x6E07: Some part of request data is invalid", false},
+		{[]byte{0x6e, 0x09}, ErrUserRejected, "Request Error 0x6E09: User rejected the action", false},
+		{[]byte{0x6e, 0x11}, ErrDeviceLocked, "Request Error 0x6E11: Pin screen", true},
+		{[]byte{0x55, 0x15}, ErrDeviceLocked, "Request Error 0x5515: Device is locked", true},
+		{[]byte{0x68, 0x07}, ErrAppNotInstalled, "Request Error 0x6807: App is not installed", false},
+		{[]byte{0x69, 0x85}, ErrUserRejected, "Request Error 0x6985: User denied the action", false},
+		{[]byte{0x01, 0x02, 0x6a, 0x80}, nil, "Request Error: 6a80", false},
+	}
+	for _, test := range tests {
+		device := NewLedger(&staticDevice{response: test.response})
+		_, err := device.GetVersion()
+		var statusErr *StatusError
+		if !errors.As(err, &statusErr) {
+			t.Fatalf("%x: WRONG error type: %v\n", test.response, err)
+		}
+		if err.Error() != test.message {
+			t.Errorf("%x: WRONG message: %v\n", test.response, err)
+		}
+		if test.target != nil && !errors.Is(err, test.target) {
+			t.Errorf("%x: error %v is not %v\n", test.response, err, test.target)
+		}
+		if errors.Is(err, ErrUserRejected) != (test.target == ErrUserRejected) {
+			t.Errorf("%x: error %v unexpectedly matches ErrUserRejected\n", test.response, err)
+		}
+		if IsRetryable(err) != test.retryable || IsTerminal(err) == test.retryable {
+			t.Errorf("%x: WRONG classification of %v\n", test.response, err)
+		}
+		if !bytes.Equal(statusErr.Response, test.response[:len(test.response)-2]) {
+			t.Errorf("%x: response is not preserved: %x\n", test.response, statusErr.Response)
+		}
+	}
+
+	// Transport errors are not classified
+	_, err := NewLedger(&staticDevice{response: []byte{0x90}}).GetVersion()
+	if err == nil || IsRetryable(err) || IsTerminal(err) {
+		t.Fatalf("WRONG short response error: %v\n", err)
+	}
+}
+
+func TestStatusErrorEmulator(t *testing.T) {
+	emulator := newTestEmulator(t)
+	emulator.User = EmulatorUserFunc(func(screens []EmulatorScreen) bool {
+		return false
+	})
+	device := NewLedger(emulator)
+
+	if _, err := device.GetAddress(StringToPath("44'/540'/0'/0/0'")); !errors.Is(err, ErrUserRejected) {
+		t.Fatalf("WRONG reject error: %v\n", err)
+	}
+	if _, err := device.GetAddress(StringToPath("44'/60'/0'/0/0'")); err == nil || err.Error() != "Wrong coin type of path m/44'/60'/0'/0/0': expected 540'" {
+		t.Fatalf("WRONG invalid path error: %v\n", err)
+	}
+}
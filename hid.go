@@ -1,13 +1,22 @@
 package ledger
 
+import (
+	"context"
+)
+
 const (
 	// LedgerUSBVendorID allows identifying USB devices made by Ledger.
 	LedgerUSBVendorID = 0x2c97
+	// LedgerUsagePage HID usage page of the Ledger APDU interface
+	LedgerUsagePage = 0xffa0
 	// ReadBuffMaxSize is the maximal number of bytes in the read buffer.
 	ReadBuffMaxSize = 2048
 )
 
-// IHidDevice HID Lenger device interface
+// IHidDevice HID Lenger device interface.
+// Ledger never calls Exchange concurrently. If the device does not implement IHidDeviceContext
+// and the context of a request is done, the request returns CanceledError, and the next request
+// waits until the abandoned Exchange returns before sending its command.
 type IHidDevice interface {
 	Open() error
 	Close()
@@ -40,4 +49,17 @@ type HidDeviceInfo struct {
 	//  * Valid on the Mac implementation if and only if the device
 	//    is a USB HID device.
 	InterfaceNumber int
+	// Device Serial Number
+	SerialNumber string
+	// Manufacturer String
+	Manufacturer string
+	// Product String
+	Product string
+}
+
+// IHidDeviceContext HID Ledger device supporting cancellation of the exchange
+type IHidDeviceContext interface {
+	IHidDevice
+	// ExchangeContext Exchange APDU, waiting for the response is aborted when the context is done.
+	ExchangeContext(ctx context.Context, apdu []byte) ([]byte, error)
 }
@@ -0,0 +1,107 @@
+//go:build !nohid && (hidraw || !cgo)
+// +build !nohid
+// +build hidraw !cgo
+
+package ledger
+
+import (
+	"crypto/rand"
+
+	"github.com/spacemeshos/go-ledger-sdk/hidframe"
+)
+
+// HidDevice Ledger device accessed through Linux hidraw node, used instead of
+// hidapi when built with the hidraw tag or without cgo
+type HidDevice struct {
+	Info HidDeviceInfo
+	hidrawNode
+
+	channel int
+	// Response to the canceled exchange, nil if there is none
+	stale *hidframe.Reassembler
+}
+
+// Open Open Ledger device for communication.
+//
+// return {error} Error value.
+//
+// example
+// devices := ledger.GetDevices(0)
+//
+//	if devices != nil && len(devices) > 0 {
+//		device := devices[0]
+//		if err := device.Open(); err == nil {
+//			...
+//			device.Close()
+//		} else {
+//			fmt.Printf("Open device ERROR: %v\n", err)
+//		}
+//	}
+func (device *HidDevice) Open() error {
+	device.stale = nil
+	return device.open(device.Info.Path)
+}
+
+// Close Close communication with Ledger device.
+//
+// example
+// devices := ledger.GetDevices(0)
+//
+//	if devices != nil && len(devices) > 0 {
+//		device := devices[0]
+//		if err := device.Open(); err == nil {
+//			...
+//			device.Close()
+//		} else {
+//			fmt.Printf("Open device ERROR: %v\n", err)
+//		}
+//	}
+func (device *HidDevice) Close() {
+	device.close()
+}
+
+// GetInfo Get HID device info
+func (device *HidDevice) GetInfo() *HidDeviceInfo {
+	return &device.Info
+}
+
+// GetDevices Enumerate Ledger devices.
+//
+// param {int} productId USB Product ID filter, 0 - all.
+// return {[]*HidDevice} Discovered Ledger devices.
+//
+// example
+// devices := ledger.GetDevices(0)
+//
+//	if devices != nil && len(devices) > 0 {
+//		device := devices[0]
+//		if err := device.Open(); err == nil {
+//			...
+//			device.Close()
+//		} else {
+//			fmt.Printf("Open device ERROR: %v\n", err)
+//		}
+//	}
+func GetDevices(productID int) []*Ledger {
+	infos, err := enumerateHidraw(cHidrawSysfsRoot, cHidrawDevRoot, productID)
+	if err != nil {
+		return nil
+	}
+	return newHidrawDevices(infos)
+}
+
+// Wrap enumerated hidraw nodes into Ledger devices with random channels
+func newHidrawDevices(infos []HidDeviceInfo) []*Ledger {
+	devices := make([]*Ledger, 0, len(infos))
+	for _, info := range infos {
+		device := &HidDevice{Info: info}
+		b := make([]byte, 2)
+		_, err := rand.Read(b)
+		if err != nil {
+			return nil
+		}
+		device.channel = int(b[1])<<8 | int(b[0])
+		devices = append(devices, &Ledger{hid: device})
+	}
+	return devices
+}
@@ -0,0 +1,173 @@
+//go:build !nohid && (hidraw || !cgo)
+// +build !nohid
+// +build hidraw !cgo
+
+package ledger
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spacemeshos/go-ledger-sdk/hidframe"
+)
+
+// Serve APDU commands framed in HID reports written to the device end of the fake node,
+// answered receives every command after its response is written, it may be nil
+func serveFakeHidraw(peer *os.File, channel int, emulator *Emulator, answered chan<- []byte) {
+	buffer := make([]byte, hidframe.PacketSize+1)
+	reassembler := hidframe.NewReassembler(uint16(channel))
+	for {
+		length, err := peer.Read(buffer)
+		if err != nil {
+			return
+		}
+		// Skip report number
+		apdu, err := reassembler.Add(buffer[1:length])
+		if err != nil {
+			return
+		}
+		if apdu == nil {
+			continue
+		}
+		response, _ := emulator.Exchange(apdu)
+		packets, _ := hidframe.Frame(uint16(channel), response)
+		for _, packet := range packets {
+			if _, err := peer.Write(packet); err != nil {
+				return
+			}
+		}
+		if answered != nil {
+			answered <- apdu
+		}
+	}
+}
+
+func TestHidrawDevice(t *testing.T) {
+	node, peer := newFakeHidrawPair(t)
+	devices := newHidrawDevices([]HidDeviceInfo{{
+		Path:      filepath.Join(t.TempDir(), "hidraw0"),
+		VendorID:  LedgerUSBVendorID,
+		ProductID: 0x1011,
+		UsagePage: LedgerUsagePage,
+	}})
+	if len(devices) != 1 {
+		t.Fatalf("WRONG devices: %v\n", devices)
+	}
+	device := devices[0]
+	if err := device.Open(); err == nil {
+		t.Fatalf("open missing node NO ERROR\n")
+	}
+
+	hid := device.hid.(*HidDevice)
+	hid.hidrawNode = *node
+	go serveFakeHidraw(peer, hid.channel, newTestEmulator(t), nil)
+
+	version, err := device.GetVersion()
+	if err != nil {
+		t.Fatalf("get version ERROR: %v\n", err)
+	}
+	if version.Major != 0 || version.Minor != 0 || version.Patch != 4 {
+		t.Fatalf("WRONG version: %+v\n", version)
+	}
+	// Public key response spans two HID reports
+	publicKey, err := device.GetExtendedPublicKey(StringToPath("44'/540'/0'/0/0'"))
+	if err != nil {
+		t.Fatalf("get public key ERROR: %v\n", err)
+	}
+	if len(publicKey.PublicKey) != PublicKeySize {
+		t.Fatalf("WRONG public key: %x\n", publicKey.PublicKey)
+	}
+	device.Close()
+	if _, err := device.GetVersion(); err == nil {
+		t.Fatalf("closed device NO ERROR\n")
+	}
+}
+
+// Start signing on fake hidraw device and cancel it before the user approves the action,
+// closing the returned channel approves it and every following action
+func cancelHidrawSigning(t *testing.T) (*Ledger, chan struct{}, chan []byte) {
+	node, peer := newFakeHidrawPair(t)
+	hid := &HidDevice{channel: 0x0101}
+	hid.hidrawNode = *node
+	emulator := newTestEmulator(t)
+	release := make(chan struct{})
+	emulator.User = EmulatorUserFunc(func(screens []EmulatorScreen) bool {
+		<-release
+		return true
+	})
+	answered := make(chan []byte, 10)
+	go serveFakeHidraw(peer, hid.channel, emulator, answered)
+	device := NewLedger(hid)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := device.SignTxContext(ctx, StringToPath("44'/540'/0'/0/0'"), newTestExecAppTx(t))
+	var canceled *CanceledError
+	if !errors.As(err, &canceled) {
+		t.Fatalf("WRONG cancel error: %v\n", err)
+	}
+	// Header and data chunks were answered before the last one
+	<-answered
+	<-answered
+	return device, release, answered
+}
+
+func checkHidrawExchanges(t *testing.T, device *Ledger) {
+	version, err := device.GetVersion()
+	if err != nil {
+		t.Fatalf("get version ERROR: %v\n", err)
+	}
+	if version.Major != 0 || version.Minor != 0 || version.Patch != 4 {
+		t.Fatalf("WRONG version: %+v\n", version)
+	}
+	publicKey, err := device.GetExtendedPublicKey(StringToPath("44'/540'/0'/0/0'"))
+	if err != nil || len(publicKey.PublicKey) != PublicKeySize {
+		t.Fatalf("WRONG public key: %+v %v\n", publicKey, err)
+	}
+}
+
+func TestHidrawDeviceLateResponse(t *testing.T) {
+	device, release, answered := cancelHidrawSigning(t)
+	// The device answers the signing command after the exchange was canceled
+	close(release)
+	<-answered
+
+	checkHidrawExchanges(t, device)
+}
+
+func TestHidrawDeviceStaleResponse(t *testing.T) {
+	device, release, answered := cancelHidrawSigning(t)
+
+	// The next request gives up while the response to the canceled one is still pending
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	var canceled *CanceledError
+	if _, err := device.GetVersionContext(ctx); !errors.As(err, &canceled) {
+		t.Fatalf("WRONG cancel error: %v\n", err)
+	}
+
+	// The device answers the signing command after the next request was sent
+	done := make(chan error, 1)
+	go func() {
+		version, err := device.GetVersion()
+		if err == nil && version.Patch != 4 {
+			err = fmt.Errorf("WRONG version: %+v", version)
+		}
+		done <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	if apdu := <-answered; apdu[1] != cInsSignTx {
+		t.Fatalf("WRONG answered command: %x\n", apdu)
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("get version ERROR: %v\n", err)
+	}
+
+	checkHidrawExchanges(t, device)
+}
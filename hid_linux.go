@@ -1,3 +1,6 @@
+//go:build cgo && !hidraw && !nohid
+// +build cgo,!hidraw,!nohid
+
 package ledger
 
 /*
@@ -10,6 +13,8 @@ import (
 	"crypto/rand"
 	"fmt"
 	"unsafe"
+
+	"github.com/spacemeshos/go-ledger-sdk/hidframe"
 )
 
 // HidDevice Wrapper for HIDAPI library
@@ -18,6 +23,8 @@ type HidDevice struct {
 	hidHandle *C.hid_device
 
 	channel int
+	// Response to the canceled exchange, nil if there is none
+	stale *hidframe.Reassembler
 }
 
 // Open Open Ledger device for communication.
@@ -38,6 +45,7 @@ type HidDevice struct {
 //	}
 func (device *HidDevice) Open() error {
 	device.closeHandle()
+	device.stale = nil
 	path := C.CString(device.Info.Path)
 	defer C.free(unsafe.Pointer(path))
 	device.hidHandle = C.hid_open_path(path)
@@ -64,6 +72,20 @@ func (device *HidDevice) read() []byte {
 	return buff[:returnedLength]
 }
 
+// Read data from Ledger waiting at most the given number of milliseconds,
+// returns empty buffer on timeout
+func (device *HidDevice) readTimeout(milliseconds int) []byte {
+	if device.hidHandle == nil {
+		return nil
+	}
+	buff := make([]byte, ReadBuffMaxSize)
+	returnedLength := C.hid_read_timeout(device.hidHandle, (*C.uchar)(&buff[0]), ReadBuffMaxSize, C.int(milliseconds))
+	if returnedLength == -1 {
+		return nil
+	}
+	return buff[:returnedLength]
+}
+
 // Close Close communication with Ledger device.
 //
 // example
@@ -105,6 +127,20 @@ func (device *HidDevice) write(buffer []byte, writeLength int) int {
 	return int(returnedLength)
 }
 
+// Convert hidapi wide string to Go string, wchar_t is UTF-32 on Linux
+func wcharToString(str *C.wchar_t) string {
+	if str == nil {
+		return ""
+	}
+	length := int(C.wcslen(str))
+	chars := (*[1 << 20]uint32)(unsafe.Pointer(str))[:length:length]
+	runes := make([]rune, length)
+	for i, c := range chars {
+		runes[i] = rune(c)
+	}
+	return string(runes)
+}
+
 // GetDevices Enumerate Ledger devices.
 //
 // param {int} productId USB Product ID filter, 0 - all.
@@ -148,6 +184,11 @@ func GetDevices(productID int) []*Ledger {
 		}
 		device.Info.UsagePage = uint16(dev.usage_page)
 		device.Info.Usage = uint16(dev.usage)
+		device.Info.ReleaseNumber = uint16(dev.release_number)
+		device.Info.InterfaceNumber = int(dev.interface_number)
+		device.Info.SerialNumber = wcharToString(dev.serial_number)
+		device.Info.Manufacturer = wcharToString(dev.manufacturer_string)
+		device.Info.Product = wcharToString(dev.product_string)
 		devices = append(devices, &Ledger{hid: device})
 	}
 
@@ -0,0 +1,16 @@
+//go:build nohid || (!linux && !cgo) || (!linux && !darwin && !windows)
+// +build nohid !linux,!cgo !linux,!darwin,!windows
+
+package ledger
+
+// hidAvailable HID backend is not compiled in, built with the nohid tag or
+// without cgo on platforms other than Linux
+const hidAvailable = false
+
+// GetDevices Enumerate Ledger devices, there are no devices without HID backend.
+//
+// param {int} productId USB Product ID filter, 0 - all.
+// return {[]*Ledger} Always nil.
+func GetDevices(productID int) []*Ledger {
+	return nil
+}
@@ -0,0 +1,34 @@
+//go:build nohid || (!linux && !cgo) || (!linux && !darwin && !windows)
+// +build nohid !linux,!cgo !linux,!darwin,!windows
+
+package ledger
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestNoHidBackend(t *testing.T) {
+	if devices := GetDevices(0); devices != nil {
+		t.Fatalf("WRONG devices: %v\n", devices)
+	}
+	if _, err := (&HidEnumerator{}).Enumerate(); !errors.Is(err, ErrNoHidBackend) {
+		t.Fatalf("WRONG enumerate error: %v\n", err)
+	}
+	if _, err := SelectDevice(context.Background(), nil, DeviceFilter{}); !errors.Is(err, ErrNoHidBackend) {
+		t.Fatalf("WRONG select error: %v\n", err)
+	}
+
+	// Emulators are available without HID backend
+	emulator := newTestEmulator(t)
+	device, err := SelectDevice(context.Background(), EnumeratorFunc(func() ([]*Ledger, error) {
+		return []*Ledger{NewLedger(emulator)}, nil
+	}), DeviceFilter{})
+	if err != nil {
+		t.Fatalf("select device ERROR: %v\n", err)
+	}
+	if _, err := device.GetVersion(); err != nil {
+		t.Fatalf("get version ERROR: %v\n", err)
+	}
+}
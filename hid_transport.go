@@ -0,0 +1,130 @@
+//go:build !nohid && (linux || (cgo && darwin) || (cgo && windows))
+// +build !nohid
+// +build linux cgo,darwin cgo,windows
+
+package ledger
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/spacemeshos/go-ledger-sdk/hidframe"
+)
+
+// hidAvailable HID backend is compiled in
+const hidAvailable = true
+
+// Interval of checking the context while waiting for the response, in milliseconds
+const cReadPollInterval = 100
+
+// Time to wait for the response to a canceled exchange before the device is closed
+const cStaleResponseTimeout = 10 * time.Second
+
+// Read data from Ledger until the context is done
+func (device *HidDevice) readContext(ctx context.Context) ([]byte, error) {
+	if ctx.Done() == nil {
+		if buffer := device.read(); buffer != nil {
+			return buffer, nil
+		}
+		return nil, fmt.Errorf("Buffer is nil")
+	}
+	for {
+		buffer := device.readTimeout(cReadPollInterval)
+		if buffer == nil {
+			return nil, fmt.Errorf("Buffer is nil")
+		}
+		if len(buffer) > 0 {
+			return buffer, nil
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// Exchange Exchange with the device using APDU protocol.
+// param apdu
+// return {[]byte} apdu response
+// return {error} Error value.
+func (device *HidDevice) Exchange(apdu []byte) ([]byte, error) {
+	return device.ExchangeContext(context.Background(), apdu)
+}
+
+// ExchangeContext Exchange with the device using APDU protocol,
+// waiting for the response is aborted when the context is done.
+// param ctx
+// param apdu
+// return {[]byte} apdu response
+// return {error} Error value.
+func (device *HidDevice) ExchangeContext(ctx context.Context, apdu []byte) ([]byte, error) {
+	packets, err := hidframe.Frame(uint16(device.channel), apdu)
+	if err != nil {
+		return nil, err
+	}
+	if device.stale != nil {
+		if err := device.discardStale(ctx); err != nil {
+			return nil, err
+		}
+	}
+	message := make([]byte, hidframe.PacketSize+1)
+	for _, packet := range packets {
+		// Report number is 0 followed by the packet
+		copy(message[1:], packet)
+		if writeLength := device.write(message, len(message)); writeLength != len(message) {
+			return nil, fmt.Errorf("writeHID error %v", writeLength)
+		}
+	}
+
+	// Read response
+	reassembler := hidframe.NewReassembler(uint16(device.channel))
+	for {
+		buffer, err := device.readContext(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				// The device will still respond
+				device.stale = reassembler
+			}
+			return nil, err
+		}
+		result, err := reassembler.Add(buffer)
+		if err != nil {
+			return nil, err
+		}
+		if result != nil {
+			return result, nil
+		}
+	}
+}
+
+// Read and drop the response to the canceled exchange, it would be taken for the response
+// to the next command. The device is closed if it does not respond in cStaleResponseTimeout.
+func (device *HidDevice) discardStale(ctx context.Context) error {
+	staleCtx, cancel := context.WithTimeout(ctx, cStaleResponseTimeout)
+	defer cancel()
+	for {
+		buffer, err := device.readContext(staleCtx)
+		if err != nil {
+			if ctx.Err() != nil {
+				// Keep waiting in the next exchange
+				return err
+			}
+			device.stale = nil
+			device.Close()
+			if staleCtx.Err() != nil {
+				return fmt.Errorf("Device did not respond to the canceled request in %v, closed the device", cStaleResponseTimeout)
+			}
+			return err
+		}
+		result, err := device.stale.Add(buffer)
+		if err != nil {
+			device.stale = nil
+			device.Close()
+			return fmt.Errorf("Invalid response to the canceled request, closed the device: %w", err)
+		}
+		if result != nil {
+			device.stale = nil
+			return nil
+		}
+	}
+}
@@ -0,0 +1,63 @@
+//go:build go1.18
+// +build go1.18
+
+package hidframe
+
+import (
+	"bytes"
+	"testing"
+)
+
+// Arbitrary packets never panic and never produce APDU longer than announced
+func FuzzReassembler(f *testing.F) {
+	f.Add([]byte{0x01, 0x01, Tag, 0, 0, 0, 2, 0x90, 0x00}, []byte{})
+	f.Add([]byte{0x01, 0x01, Tag, 0, 0, 0, 4, 0xaa, 0xbb}, []byte{0x01, 0x01, Tag, 0, 1, 0xcc, 0xdd})
+	f.Add([]byte{0x01, 0x01}, []byte{0x01, 0x01, Tag, 0, 1})
+	f.Fuzz(func(t *testing.T, first []byte, second []byte) {
+		reassembler := NewReassembler(0x0101)
+		announced := -1
+		for _, packet := range [][]byte{first, second, first} {
+			if reassembler.sequence == 0 && len(packet) >= firstHeaderSize {
+				announced = int(packet[5])<<8 | int(packet[6])
+			}
+			apdu, err := reassembler.Add(packet)
+			if err != nil && apdu != nil {
+				t.Fatalf("APDU with error: %x %v", apdu, err)
+			}
+			if apdu != nil && len(apdu) != announced {
+				t.Fatalf("WRONG APDU length: expected %v, got %v", announced, len(apdu))
+			}
+		}
+	})
+}
+
+// Any APDU survives framing and reassembly
+func FuzzFrame(f *testing.F) {
+	f.Add(uint16(0x0101), []byte{0xe0, 0x01, 0x00, 0x00, 0x00})
+	f.Add(uint16(0), bytes.Repeat([]byte{0xa5}, 200))
+	f.Fuzz(func(t *testing.T, channel uint16, apdu []byte) {
+		packets, err := Frame(channel, apdu)
+		if err != nil {
+			if len(apdu) <= MaxAPDUSize {
+				t.Fatalf("frame ERROR: %v", err)
+			}
+			return
+		}
+		reassembler := NewReassembler(channel)
+		var result []byte
+		for i, packet := range packets {
+			if len(packet) != PacketSize {
+				t.Fatalf("WRONG packet length: %v", len(packet))
+			}
+			if result != nil {
+				t.Fatalf("APDU completed before packet %v", i)
+			}
+			if result, err = reassembler.Add(packet); err != nil {
+				t.Fatalf("reassemble ERROR: %v", err)
+			}
+		}
+		if !bytes.Equal(result, apdu) || result == nil {
+			t.Fatalf("WRONG APDU: %x", result)
+		}
+	})
+}
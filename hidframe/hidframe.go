@@ -0,0 +1,156 @@
+// Package hidframe Ledger HID framing of APDU commands and responses.
+//
+// APDU is split into 64 bytes HID packets. Every packet starts with 2 bytes channel,
+// 0x05 tag and 2 bytes sequence index, the first packet also holds 2 bytes APDU length.
+// The packets do not contain HID report number, it is added by the transport if needed.
+package hidframe
+
+import (
+	"errors"
+	"fmt"
+)
+
+const (
+	// Tag Tag of APDU packets
+	Tag = 0x05
+	// PacketSize Size of HID packet
+	PacketSize = 64
+	// MaxAPDUSize Maximal APDU length encodable in the first packet
+	MaxAPDUSize = 0xffff
+	// Size of header of all packets: channel, tag, sequence
+	headerSize = 5
+	// Size of header of the first packet: channel, tag, sequence, APDU length
+	firstHeaderSize = headerSize + 2
+)
+
+var (
+	// ErrShortPacket Packet is shorter than its header
+	ErrShortPacket = errors.New("Packet too short")
+	// ErrInvalidChannel Packet belongs to other channel
+	ErrInvalidChannel = errors.New("Invalid channel")
+	// ErrInvalidTag Packet has unknown tag
+	ErrInvalidTag = errors.New("Invalid tag")
+	// ErrInvalidSequence Packet is out of sequence
+	ErrInvalidSequence = errors.New("Invalid sequence")
+	// ErrAPDUTooLong APDU length does not fit the first packet
+	ErrAPDUTooLong = errors.New("APDU too long")
+)
+
+// Frame Split APDU into HID packets, the last packet is padded with zeros.
+//
+// param {uint16} channel Channel of the packets.
+// param {[]byte} apdu APDU command or response.
+// return {[][]byte} Packets of PacketSize bytes.
+// return {error} Error value, ErrAPDUTooLong if the APDU is longer than MaxAPDUSize.
+//
+// example
+// packets, err := hidframe.Frame(channel, apdu)
+//
+//	for _, packet := range packets {
+//		// write report number and packet
+//	}
+func Frame(channel uint16, apdu []byte) ([][]byte, error) {
+	if len(apdu) > MaxAPDUSize {
+		return nil, fmt.Errorf("%w: expected at most %v, got %v", ErrAPDUTooLong, MaxAPDUSize, len(apdu))
+	}
+	count := 1
+	if len(apdu) > PacketSize-firstHeaderSize {
+		count += (len(apdu) - (PacketSize - firstHeaderSize) + PacketSize - headerSize - 1) / (PacketSize - headerSize)
+	}
+	packets := make([][]byte, 0, count)
+	offset := 0
+	for sequence := 0; sequence < count; sequence++ {
+		packet := make([]byte, PacketSize)
+		packet[0] = byte(channel >> 8)
+		packet[1] = byte(channel)
+		packet[2] = Tag
+		packet[3] = byte(sequence >> 8)
+		packet[4] = byte(sequence)
+		header := headerSize
+		if sequence == 0 {
+			packet[5] = byte(len(apdu) >> 8)
+			packet[6] = byte(len(apdu))
+			header = firstHeaderSize
+		}
+		offset += copy(packet[header:], apdu[offset:])
+		packets = append(packets, packet)
+	}
+	return packets, nil
+}
+
+// Reassembler Collect HID packets of one channel into APDU
+type Reassembler struct {
+	channel  uint16
+	data     []byte
+	length   int
+	sequence int
+}
+
+// NewReassembler Create reassembler accepting packets of the given channel
+func NewReassembler(channel uint16) *Reassembler {
+	return &Reassembler{channel: channel}
+}
+
+// Add Add next packet, packets longer than PacketSize are accepted, padding is dropped.
+//
+// param {[]byte} packet HID packet without report number.
+// return {[]byte} APDU when the packet completes it, nil otherwise.
+// return {error} Error value, the reassembler is reset on error.
+//
+// example
+// reassembler := hidframe.NewReassembler(channel)
+//
+//	for {
+//		apdu, err := reassembler.Add(readPacket())
+//		if err != nil || apdu != nil {
+//			return apdu, err
+//		}
+//	}
+func (reassembler *Reassembler) Add(packet []byte) ([]byte, error) {
+	header := headerSize
+	if reassembler.sequence == 0 {
+		header = firstHeaderSize
+	}
+	if len(packet) < header {
+		reassembler.Reset()
+		return nil, fmt.Errorf("%w: expected at least %v, got %v", ErrShortPacket, header, len(packet))
+	}
+	if channel := uint16(packet[0])<<8 | uint16(packet[1]); channel != reassembler.channel {
+		reassembler.Reset()
+		return nil, fmt.Errorf("%w: expected %v, got %v", ErrInvalidChannel, reassembler.channel, channel)
+	}
+	if packet[2] != Tag {
+		reassembler.Reset()
+		return nil, fmt.Errorf("%w: expected %v, got %v", ErrInvalidTag, Tag, packet[2])
+	}
+	if sequence := int(packet[3])<<8 | int(packet[4]); sequence != reassembler.sequence {
+		expected := reassembler.sequence
+		reassembler.Reset()
+		return nil, fmt.Errorf("%w: expected %v, got %v", ErrInvalidSequence, expected, sequence)
+	}
+
+	if reassembler.sequence == 0 {
+		reassembler.length = int(packet[5])<<8 | int(packet[6])
+		reassembler.data = make([]byte, 0, reassembler.length)
+	}
+	chunk := packet[header:]
+	if remaining := reassembler.length - len(reassembler.data); len(chunk) > remaining {
+		chunk = chunk[:remaining]
+	}
+	reassembler.data = append(reassembler.data, chunk...)
+	reassembler.sequence++
+
+	if len(reassembler.data) < reassembler.length {
+		return nil, nil
+	}
+	apdu := reassembler.data
+	reassembler.Reset()
+	return apdu, nil
+}
+
+// Reset Drop partially collected APDU
+func (reassembler *Reassembler) Reset() {
+	reassembler.data = nil
+	reassembler.length = 0
+	reassembler.sequence = 0
+}
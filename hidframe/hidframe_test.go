@@ -0,0 +1,121 @@
+package hidframe
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"testing"
+	"testing/quick"
+)
+
+func TestFrame(t *testing.T) {
+	apdu := make([]byte, 150)
+	for i := range apdu {
+		apdu[i] = byte(i)
+	}
+	packets, err := Frame(0x0101, apdu)
+	if err != nil {
+		t.Fatalf("frame ERROR: %v\n", err)
+	}
+	// 57 bytes in the first packet, 59 bytes in the next ones
+	if len(packets) != 3 {
+		t.Fatalf("WRONG packet count: %v\n", len(packets))
+	}
+	expected := []string{
+		"010105000000960001020304050607",
+		"0101050001393a",
+		"01010500027475",
+	}
+	for i, packet := range packets {
+		if len(packet) != PacketSize {
+			t.Fatalf("WRONG packet %v length: %v\n", i, len(packet))
+		}
+		if prefix := hex.EncodeToString(packet[:len(expected[i])/2]); prefix != expected[i] {
+			t.Fatalf("WRONG packet %v: %v\n", i, prefix)
+		}
+	}
+	// Padding after the last byte 0x95
+	if packets[2][5+33] != 0x95 || !bytes.Equal(packets[2][5+34:], make([]byte, PacketSize-5-34)) {
+		t.Fatalf("WRONG last packet: %x\n", packets[2])
+	}
+
+	packets, err = Frame(0, nil)
+	if err != nil || len(packets) != 1 || !bytes.Equal(packets[0], append([]byte{0, 0, Tag}, make([]byte, PacketSize-3)...)) {
+		t.Fatalf("WRONG empty APDU packets: %x %v\n", packets, err)
+	}
+	if _, err := Frame(0, make([]byte, MaxAPDUSize+1)); !errors.Is(err, ErrAPDUTooLong) {
+		t.Fatalf("WRONG too long error: %v\n", err)
+	}
+}
+
+func TestReassembler(t *testing.T) {
+	reassembler := NewReassembler(0x0101)
+	tests := []struct {
+		packet string
+		apdu   string
+		err    error
+	}{
+		// Response of 2 bytes, padding dropped
+		{"0101050000000290000000", "9000", nil},
+		// Two packets
+		{"01010500000004aabb", "", nil},
+		{"0101050001ccdd", "aabbccdd", nil},
+		{"", "", ErrShortPacket},
+		{"010105000000", "", ErrShortPacket},
+		{"01020500000002aabb", "", ErrInvalidChannel},
+		{"01010600000002aabb", "", ErrInvalidTag},
+		{"01010500010002aabb", "", ErrInvalidSequence},
+		// Reset after error, second packet expected after the first one
+		{"01010500000004aabb", "", nil},
+		{"01010500", "", ErrShortPacket},
+		{"0101050001ccdd", "", ErrInvalidSequence},
+		{"01010500000000", "", nil},
+	}
+	for i, test := range tests {
+		packet, _ := hex.DecodeString(test.packet)
+		apdu, err := reassembler.Add(packet)
+		if !errors.Is(err, test.err) {
+			t.Fatalf("WRONG error of packet %v: %v\n", i, err)
+		}
+		if hex.EncodeToString(apdu) != test.apdu {
+			t.Fatalf("WRONG APDU of packet %v: %x\n", i, apdu)
+		}
+	}
+	// Empty APDU completes with the first packet
+	apdu, err := reassembler.Add([]byte{1, 1, Tag, 0, 0, 0, 0})
+	if err != nil || apdu == nil || len(apdu) != 0 {
+		t.Fatalf("WRONG empty APDU: %x %v\n", apdu, err)
+	}
+}
+
+// Reassembled packets of any APDU give the same APDU
+func TestFrameRoundTrip(t *testing.T) {
+	roundTrip := func(channel uint16, apdu []byte) bool {
+		packets, err := Frame(channel, apdu)
+		if err != nil {
+			return false
+		}
+		reassembler := NewReassembler(channel)
+		for i, packet := range packets {
+			result, err := reassembler.Add(packet)
+			if err != nil {
+				return false
+			}
+			if (result != nil) != (i == len(packets)-1) {
+				return false
+			}
+			if result != nil {
+				return bytes.Equal(result, apdu)
+			}
+		}
+		return false
+	}
+	if err := quick.Check(roundTrip, nil); err != nil {
+		t.Fatal(err)
+	}
+	for _, length := range []int{57, 58, 116, 117, 1000, MaxAPDUSize} {
+		if !roundTrip(0xffff, bytes.Repeat([]byte{0xa5}, length)) {
+			t.Fatalf("WRONG round trip of %v bytes\n", length)
+		}
+	}
+}
@@ -0,0 +1,308 @@
+//go:build !nohid
+// +build !nohid
+
+package ledger
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	// Directory listing hidraw nodes in sysfs
+	cHidrawSysfsRoot = "/sys/class/hidraw"
+	// Directory holding hidraw device nodes
+	cHidrawDevRoot = "/dev"
+	// HID bus type of USB devices, see linux/input.h
+	cHidBusUSB = 0x03
+)
+
+// hidrawNode Linux hidraw device node, read and written directly without hidapi
+type hidrawNode struct {
+	file *os.File
+}
+
+// Open hidraw device node
+func (node *hidrawNode) open(path string) error {
+	node.close()
+	file, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return fmt.Errorf("cannot open device with path %v: %w", path, err)
+	}
+	node.file = file
+	return nil
+}
+
+func (node *hidrawNode) close() {
+	if node.file != nil {
+		node.file.Close()
+		node.file = nil
+	}
+}
+
+// Read data from Ledger, returns nil on error
+func (node *hidrawNode) read() []byte {
+	if node.file == nil {
+		return nil
+	}
+	// Clear deadline possibly left by readTimeout, not every file supports deadlines
+	if err := node.file.SetReadDeadline(time.Time{}); err != nil && !errors.Is(err, os.ErrNoDeadline) {
+		return nil
+	}
+	buff := make([]byte, ReadBuffMaxSize)
+	returnedLength, err := node.file.Read(buff)
+	if err != nil {
+		return nil
+	}
+	return buff[:returnedLength]
+}
+
+// Read data from Ledger waiting at most the given number of milliseconds,
+// returns empty buffer on timeout
+func (node *hidrawNode) readTimeout(milliseconds int) []byte {
+	if node.file == nil {
+		return nil
+	}
+	if milliseconds <= 0 {
+		return node.readNonblocking()
+	}
+	deadline := time.Now().Add(time.Duration(milliseconds) * time.Millisecond)
+	if err := node.file.SetReadDeadline(deadline); err != nil {
+		if errors.Is(err, os.ErrNoDeadline) {
+			return node.read()
+		}
+		return nil
+	}
+	buff := make([]byte, ReadBuffMaxSize)
+	returnedLength, err := node.file.Read(buff)
+	if os.IsTimeout(err) {
+		return []byte{}
+	}
+	if err != nil {
+		return nil
+	}
+	return buff[:returnedLength]
+}
+
+// Read pending report without waiting, the poller would fail an expired deadline
+// before trying to read. Returns empty buffer if no report is pending.
+func (node *hidrawNode) readNonblocking() []byte {
+	if err := node.file.SetReadDeadline(time.Time{}); err != nil {
+		if errors.Is(err, os.ErrNoDeadline) {
+			// Blocking file, a read could wait forever
+			return []byte{}
+		}
+		return nil
+	}
+	conn, err := node.file.SyscallConn()
+	if err != nil {
+		return nil
+	}
+	buff := make([]byte, ReadBuffMaxSize)
+	returnedLength := -1
+	var readErr error
+	err = conn.Read(func(fd uintptr) bool {
+		returnedLength, readErr = syscall.Read(int(fd), buff)
+		return true
+	})
+	if err != nil {
+		return nil
+	}
+	if readErr == syscall.EAGAIN {
+		return []byte{}
+	}
+	if readErr != nil || returnedLength < 0 {
+		return nil
+	}
+	return buff[:returnedLength]
+}
+
+// Write data to Ledger, the first byte is the report number, 0 for Ledger devices
+func (node *hidrawNode) write(buffer []byte, writeLength int) int {
+	if node.file == nil {
+		return -1
+	}
+
+	if writeLength <= 0 || writeLength > len(buffer) {
+		return -1
+	}
+
+	returnedLength, err := node.file.Write(buffer[:writeLength])
+	if err != nil {
+		return -1
+	}
+
+	return returnedLength
+}
+
+// enumerateHidraw List Ledger hidraw nodes described in sysRoot, usually /sys/class/hidraw,
+// the node paths are placed in devRoot, usually /dev.
+// param {int} productID USB Product ID filter, 0 - all.
+func enumerateHidraw(sysRoot string, devRoot string, productID int) ([]HidDeviceInfo, error) {
+	entries, err := ioutil.ReadDir(sysRoot)
+	if err != nil {
+		return nil, err
+	}
+	infos := make([]HidDeviceInfo, 0)
+	for _, entry := range entries {
+		info, err := readHidrawInfo(filepath.Join(sysRoot, entry.Name()))
+		if err != nil {
+			// Device removed while enumerating or not a HID device we understand
+			continue
+		}
+		if info.VendorID != LedgerUSBVendorID || info.UsagePage != LedgerUsagePage {
+			continue
+		}
+		if productID != 0 && int(info.ProductID) != productID {
+			continue
+		}
+		info.Path = filepath.Join(devRoot, entry.Name())
+		infos = append(infos, *info)
+	}
+	return infos, nil
+}
+
+// Read device info of sysfs hidraw entry, the Path field is not set
+func readHidrawInfo(entry string) (*HidDeviceInfo, error) {
+	// The HID device directory, its parents are the USB interface and the USB device
+	hidDir, err := filepath.EvalSymlinks(filepath.Join(entry, "device"))
+	if err != nil {
+		return nil, err
+	}
+	uevent, err := ioutil.ReadFile(filepath.Join(hidDir, "uevent"))
+	if err != nil {
+		return nil, err
+	}
+	properties := parseUevent(uevent)
+	bus, vendorID, productID, err := parseHidID(properties["HID_ID"])
+	if err != nil {
+		return nil, err
+	}
+	descriptor, err := ioutil.ReadFile(filepath.Join(hidDir, "report_descriptor"))
+	if err != nil {
+		return nil, err
+	}
+	info := &HidDeviceInfo{
+		VendorID:        vendorID,
+		ProductID:       productID,
+		SerialNumber:    properties["HID_UNIQ"],
+		Product:         properties["HID_NAME"],
+		InterfaceNumber: -1,
+	}
+	info.UsagePage, info.Usage = parseReportDescriptorUsage(descriptor)
+
+	if bus == cHidBusUSB {
+		interfaceDir := filepath.Dir(hidDir)
+		usbDir := filepath.Dir(interfaceDir)
+		if value, err := readSysfsHex(filepath.Join(interfaceDir, "bInterfaceNumber")); err == nil {
+			info.InterfaceNumber = int(value)
+		}
+		if value, err := readSysfsHex(filepath.Join(usbDir, "bcdDevice")); err == nil {
+			info.ReleaseNumber = uint16(value)
+		}
+		if value, err := readSysfsString(filepath.Join(usbDir, "manufacturer")); err == nil {
+			info.Manufacturer = value
+		}
+		if value, err := readSysfsString(filepath.Join(usbDir, "product")); err == nil {
+			info.Product = value
+		}
+		if value, err := readSysfsString(filepath.Join(usbDir, "serial")); err == nil && info.SerialNumber == "" {
+			info.SerialNumber = value
+		}
+	}
+	return info, nil
+}
+
+// Parse KEY=VALUE lines of sysfs uevent file
+func parseUevent(data []byte) map[string]string {
+	properties := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if index := strings.IndexByte(line, '='); index > 0 {
+			properties[line[:index]] = line[index+1:]
+		}
+	}
+	return properties
+}
+
+// Parse HID_ID uevent property, e.g. "0003:00002C97:00001011"
+func parseHidID(value string) (bus uint16, vendorID uint16, productID uint16, err error) {
+	parts := strings.Split(value, ":")
+	if len(parts) != 3 {
+		return 0, 0, 0, fmt.Errorf("Invalid HID_ID %q", value)
+	}
+	values := make([]uint64, len(parts))
+	for i, part := range parts {
+		if values[i], err = strconv.ParseUint(part, 16, 32); err != nil {
+			return 0, 0, 0, fmt.Errorf("Invalid HID_ID %q: %w", value, err)
+		}
+	}
+	return uint16(values[0]), uint16(values[1]), uint16(values[2]), nil
+}
+
+// Return the first usage page and usage of HID report descriptor, 0 if not found
+func parseReportDescriptorUsage(descriptor []byte) (usagePage uint16, usage uint16) {
+	foundPage, foundUsage := false, false
+	for i := 0; i < len(descriptor) && !(foundPage && foundUsage); {
+		key := descriptor[i]
+		if key == 0xfe {
+			// Long item: key, data size, long item tag, data
+			if i+1 >= len(descriptor) {
+				break
+			}
+			i += 3 + int(descriptor[i+1])
+			continue
+		}
+		size := int(key & 0x03)
+		if size == 3 {
+			size = 4
+		}
+		if i+1+size > len(descriptor) {
+			break
+		}
+		var value uint32
+		for j := 0; j < size; j++ {
+			value |= uint32(descriptor[i+1+j]) << (8 * j)
+		}
+		switch key & 0xfc {
+		case 0x04: // Usage Page, global item
+			if !foundPage {
+				usagePage, foundPage = uint16(value), true
+			}
+		case 0x08: // Usage, local item
+			if !foundUsage {
+				usage, foundUsage = uint16(value), true
+			}
+		}
+		i += 1 + size
+	}
+	return usagePage, usage
+}
+
+// Read sysfs attribute holding a string
+func readSysfsString(path string) (string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// Read sysfs attribute holding a hexadecimal number
+func readSysfsHex(path string) (uint64, error) {
+	value, err := readSysfsString(path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseUint(value, 16, 32)
+}
@@ -0,0 +1,235 @@
+//go:build !nohid
+// +build !nohid
+
+package ledger
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/spacemeshos/go-ledger-sdk/hidframe"
+)
+
+// Report descriptors starting with usage page and usage items
+var (
+	ledgerReportDescriptor   = []byte{0x06, 0xa0, 0xff, 0x09, 0x01, 0xa1, 0x01, 0x09, 0x03, 0x15, 0x00, 0x26, 0xff, 0x00, 0x75, 0x08, 0x95, 0x40, 0x81, 0x08, 0xc0}
+	fidoReportDescriptor     = []byte{0x06, 0xd0, 0xf1, 0x09, 0x01, 0xa1, 0x01, 0xc0}
+	keyboardReportDescriptor = []byte{0x05, 0x01, 0x09, 0x06, 0xa1, 0x01, 0xc0}
+)
+
+// Fake USB HID interface exposed as hidraw node in sysfs
+type fakeHidraw struct {
+	name       string
+	usbDevice  string
+	iface      int
+	hidID      string
+	hidName    string
+	hidUniq    string
+	descriptor []byte
+}
+
+// Create sysfs tree of the fake nodes under root/sys, return class/hidraw directory
+func makeFakeSysfs(t *testing.T, root string, nodes []fakeHidraw) string {
+	classDir := filepath.Join(root, "sys", "class", "hidraw")
+	mkdir(t, classDir)
+	for _, node := range nodes {
+		usbDir := filepath.Join(root, "sys", "devices", "pci0000:00", "usb1", node.usbDevice)
+		interfaceDir := filepath.Join(usbDir, fmt.Sprintf("%v:1.%v", node.usbDevice, node.iface))
+		hidDir := filepath.Join(interfaceDir, fmt.Sprintf("%v.%04X", node.hidID[5:], node.iface))
+		nodeDir := filepath.Join(hidDir, "hidraw", node.name)
+		mkdir(t, nodeDir)
+		writeFile(t, filepath.Join(usbDir, "manufacturer"), "Ledger\n")
+		writeFile(t, filepath.Join(usbDir, "product"), "Nano S Plus\n")
+		writeFile(t, filepath.Join(usbDir, "serial"), "0001\n")
+		writeFile(t, filepath.Join(usbDir, "bcdDevice"), "0201\n")
+		writeFile(t, filepath.Join(interfaceDir, "bInterfaceNumber"), fmt.Sprintf("%02x\n", node.iface))
+		writeFile(t, filepath.Join(hidDir, "uevent"), "DRIVER=hid-generic\n"+
+			"HID_ID="+node.hidID+"\n"+
+			"HID_NAME="+node.hidName+"\n"+
+			"HID_PHYS=usb-0000:00:14.0-1/input0\n"+
+			"HID_UNIQ="+node.hidUniq+"\n"+
+			"MODALIAS=hid:b0003g0001v00002C97p00005011\n")
+		writeFile(t, filepath.Join(hidDir, "report_descriptor"), string(node.descriptor))
+		symlink(t, "../..", filepath.Join(nodeDir, "device"))
+		symlink(t, nodeDir, filepath.Join(classDir, node.name))
+	}
+	return classDir
+}
+
+func mkdir(t *testing.T, path string) {
+	if err := os.MkdirAll(path, 0755); err != nil {
+		t.Fatalf("mkdir ERROR: %v\n", err)
+	}
+}
+
+func writeFile(t *testing.T, path string, data string) {
+	if err := ioutil.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatalf("write file ERROR: %v\n", err)
+	}
+}
+
+func symlink(t *testing.T, target string, path string) {
+	if err := os.Symlink(target, path); err != nil {
+		t.Fatalf("symlink ERROR: %v\n", err)
+	}
+}
+
+func TestEnumerateHidraw(t *testing.T) {
+	root := t.TempDir()
+	sysRoot := makeFakeSysfs(t, root, []fakeHidraw{
+		{"hidraw0", "1-1", 0, "0003:00002C97:00005011", "Ledger Nano S Plus", "", ledgerReportDescriptor},
+		{"hidraw1", "1-1", 1, "0003:00002C97:00005011", "Ledger Nano S Plus", "", fidoReportDescriptor},
+		{"hidraw2", "1-2", 0, "0003:0000046D:0000C31C", "Logitech Keyboard", "", keyboardReportDescriptor},
+		{"hidraw3", "1-3", 0, "0003:00002C97:00004011", "Ledger Nano X", "0002", ledgerReportDescriptor},
+	})
+	// Entry without device link, e.g. removed while enumerating
+	mkdir(t, filepath.Join(sysRoot, "hidraw4"))
+	devRoot := filepath.Join(root, "dev")
+
+	infos, err := enumerateHidraw(sysRoot, devRoot, 0)
+	if err != nil {
+		t.Fatalf("enumerate ERROR: %v\n", err)
+	}
+	if len(infos) != 2 {
+		t.Fatalf("WRONG devices: %+v\n", infos)
+	}
+	expected := HidDeviceInfo{
+		Path:            filepath.Join(devRoot, "hidraw0"),
+		VendorID:        LedgerUSBVendorID,
+		ProductID:       0x5011,
+		ReleaseNumber:   0x0201,
+		UsagePage:       LedgerUsagePage,
+		Usage:           1,
+		InterfaceNumber: 0,
+		SerialNumber:    "0001",
+		Manufacturer:    "Ledger",
+		Product:         "Nano S Plus",
+	}
+	if infos[0] != expected {
+		t.Fatalf("WRONG device info: %+v\n", infos[0])
+	}
+	if model := infos[0].Model(); model == nil || model.ID != "nanoSP" {
+		t.Fatalf("WRONG model: %v\n", model)
+	}
+	// HID_UNIQ takes precedence over the USB serial attribute
+	if infos[1].Path != filepath.Join(devRoot, "hidraw3") || infos[1].SerialNumber != "0002" {
+		t.Fatalf("WRONG device info: %+v\n", infos[1])
+	}
+
+	infos, err = enumerateHidraw(sysRoot, devRoot, 0x4011)
+	if err != nil || len(infos) != 1 || infos[0].ProductID != 0x4011 {
+		t.Fatalf("WRONG filtered devices: %+v %v\n", infos, err)
+	}
+
+	if _, err := enumerateHidraw(filepath.Join(root, "missing"), devRoot, 0); err == nil {
+		t.Fatalf("enumerate missing sysfs NO ERROR\n")
+	}
+}
+
+func TestParseReportDescriptorUsage(t *testing.T) {
+	tests := []struct {
+		descriptor []byte
+		usagePage  uint16
+		usage      uint16
+	}{
+		{ledgerReportDescriptor, 0xffa0, 0x01},
+		{fidoReportDescriptor, 0xf1d0, 0x01},
+		{keyboardReportDescriptor, 0x01, 0x06},
+		// Long item skipped
+		{[]byte{0xfe, 0x02, 0x10, 0xaa, 0xbb, 0x06, 0xa0, 0xff, 0x09, 0x01}, 0xffa0, 0x01},
+		// 4 bytes usage
+		{[]byte{0x05, 0x0c, 0x0b, 0x01, 0x00, 0x0c, 0x00}, 0x0c, 0x01},
+		// Truncated item
+		{[]byte{0x06, 0xa0}, 0, 0},
+		{nil, 0, 0},
+	}
+	for _, test := range tests {
+		usagePage, usage := parseReportDescriptorUsage(test.descriptor)
+		if usagePage != test.usagePage || usage != test.usage {
+			t.Fatalf("WRONG usage of %x: %#x %#x\n", test.descriptor, usagePage, usage)
+		}
+	}
+}
+
+// Create connected pair of hidraw node and the device end keeping HID report boundaries
+func newFakeHidrawPair(t *testing.T) (*hidrawNode, *os.File) {
+	fds, err := syscall.Socketpair(syscall.AF_UNIX, syscall.SOCK_SEQPACKET, 0)
+	if err != nil {
+		t.Fatalf("socketpair ERROR: %v\n", err)
+	}
+	for _, fd := range fds {
+		// Non-blocking descriptors support read deadlines like hidraw nodes
+		if err := syscall.SetNonblock(fd, true); err != nil {
+			t.Fatalf("set non-blocking ERROR: %v\n", err)
+		}
+	}
+	node := &hidrawNode{file: os.NewFile(uintptr(fds[0]), "hidraw")}
+	peer := os.NewFile(uintptr(fds[1]), "device")
+	t.Cleanup(func() {
+		node.close()
+		peer.Close()
+	})
+	return node, peer
+}
+
+func TestHidrawNode(t *testing.T) {
+	node, peer := newFakeHidrawPair(t)
+
+	if buffer := node.readTimeout(10); buffer == nil || len(buffer) != 0 {
+		t.Fatalf("WRONG read timeout result: %v\n", buffer)
+	}
+	if buffer := node.readTimeout(0); buffer == nil || len(buffer) != 0 {
+		t.Fatalf("WRONG non-blocking read result: %v\n", buffer)
+	}
+
+	report := make([]byte, hidframe.PacketSize+1)
+	report[1] = 0xaa
+	if length := node.write(report, 10); length != 10 {
+		t.Fatalf("WRONG write length: %v\n", length)
+	}
+	if length := node.write(report, len(report)+1); length != -1 {
+		t.Fatalf("WRONG write length: %v\n", length)
+	}
+	buffer := make([]byte, 128)
+	length, err := peer.Read(buffer)
+	if err != nil || !bytes.Equal(buffer[:length], report[:10]) {
+		t.Fatalf("WRONG written report: %x %v\n", buffer[:length], err)
+	}
+
+	for i := range report {
+		report[i] = byte(i)
+	}
+	if _, err := peer.Write(report[:hidframe.PacketSize]); err != nil {
+		t.Fatalf("write ERROR: %v\n", err)
+	}
+	if buffer := node.readTimeout(1000); !bytes.Equal(buffer, report[:hidframe.PacketSize]) {
+		t.Fatalf("WRONG read report: %x\n", buffer)
+	}
+	if _, err := peer.Write(report[2:hidframe.PacketSize]); err != nil {
+		t.Fatalf("write ERROR: %v\n", err)
+	}
+	// Pending report is read without waiting
+	if buffer := node.readTimeout(0); !bytes.Equal(buffer, report[2:hidframe.PacketSize]) {
+		t.Fatalf("WRONG read report: %x\n", buffer)
+	}
+	if _, err := peer.Write(report[1:hidframe.PacketSize]); err != nil {
+		t.Fatalf("write ERROR: %v\n", err)
+	}
+	// Blocking read after timed out read
+	if buffer := node.read(); !bytes.Equal(buffer, report[1:hidframe.PacketSize]) {
+		t.Fatalf("WRONG read report: %x\n", buffer)
+	}
+
+	node.close()
+	if node.read() != nil || node.readTimeout(10) != nil || node.readTimeout(0) != nil || node.write(report, 10) != -1 {
+		t.Fatalf("closed node NO ERROR\n")
+	}
+	if err := node.open(filepath.Join(t.TempDir(), "hidraw0")); err == nil {
+		t.Fatalf("open missing node NO ERROR\n")
+	}
+}
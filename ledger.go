@@ -1,8 +1,10 @@
 package ledger
 
 import (
+	"context"
 	"encoding/binary"
 	"fmt"
+	"sync"
 )
 
 const (
@@ -10,6 +12,8 @@ const (
 	cCLA = 0x30
 	// Max length of APDU packet supported by Ledger device
 	cMaxPacketLength = 240
+	// Max number of BIP32 path indexes supported by Ledger device
+	cMaxPathLength = 10
 
 	// Instruction code for GetVersion command
 	cInsGetVersion = 0x00
@@ -40,6 +44,16 @@ const (
 // Ledger struct
 type Ledger struct {
 	hid IHidDevice
+	// Opened with Open and not closed since
+	opened bool
+	// Source of devices to reconnect, HidEnumerator if nil
+	enumerator Enumerator
+
+	verifySignatures bool
+	publicKeys       map[string][]byte
+	mutex            sync.Mutex
+
+	exchanger hidExchanger
 }
 
 // Version struct
@@ -62,16 +76,13 @@ type BipPath []uint32
 // Extract return code from response.
 // param {[]byte} Response data
 // return {[]byte} Response data without return code
-// return {uint32} Error code.
+// return {uint32} Error code, 0 if the response is too short.
 func stripRetcodeFromResponse(response []byte) ([]byte, uint32) {
 	L := len(response)
 	if L < 2 {
 		return nil, 0
 	}
-	if response[L-2] != 0x90 || response[L-1] != 0x00 { // OK code 0x9000
-		return nil, uint32(binary.BigEndian.Uint16(response[L-2:]))
-	}
-	return response[:L-2], 0x9000
+	return response[:L-2], uint32(binary.BigEndian.Uint16(response[L-2:]))
 }
 
 // GetHidInfo Get HID device info
@@ -81,61 +92,119 @@ func (device *Ledger) GetHidInfo() *HidDeviceInfo {
 
 // Open Ledger device
 func (device *Ledger) Open() error {
-	return device.hid.Open()
+	err := device.hid.Open()
+	device.opened = err == nil
+	return err
 }
 
 // Close Ledger device
 func (device *Ledger) Close() {
+	device.opened = false
 	device.hid.Close()
 }
 
-// Wrapper on top of exchange to simplify work of the implementation.
+// Exchange APDU with the device, stop waiting for the response when the context is done.
+// param ctx  Context
+// param apdu APDU packet
+// return {[]byte} Response data with return code
+// return {error} Error value, CanceledError when the context is done.
+func (device *Ledger) exchange(ctx context.Context, apdu []byte) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, &CanceledError{Err: err}
+	}
+
+	return device.exchanger.exchange(ctx, device.hid, apdu)
+}
+
+// Exchanges with one HID device, Exchange of the device is never called concurrently
+type hidExchanger struct {
+	// Closed when the exchange abandoned because the context was done returns, nil if there is none
+	abandoned chan struct{}
+	mutex     sync.Mutex
+}
+
+// Take over the exchange abandoned by other exchanger of the same device
+func (exchanger *hidExchanger) inherit(other *hidExchanger) {
+	other.mutex.Lock()
+	abandoned := other.abandoned
+	other.mutex.Unlock()
+	exchanger.mutex.Lock()
+	exchanger.abandoned = abandoned
+	exchanger.mutex.Unlock()
+}
+
+// Exchange APDU with HID device, waiting for the response in a goroutine
+// if the device does not support cancellation. The exchange abandoned when the context
+// was done must complete before the next command is sent.
+// return {error} Error value, CanceledError when the context is done.
+func (exchanger *hidExchanger) exchange(ctx context.Context, hid IHidDevice, apdu []byte) ([]byte, error) {
+	exchanger.mutex.Lock()
+	abandoned := exchanger.abandoned
+	exchanger.mutex.Unlock()
+	if abandoned != nil {
+		select {
+		case <-abandoned:
+			exchanger.mutex.Lock()
+			if exchanger.abandoned == abandoned {
+				exchanger.abandoned = nil
+			}
+			exchanger.mutex.Unlock()
+		case <-ctx.Done():
+			return nil, &CanceledError{Err: ctx.Err()}
+		}
+	}
+
+	var response []byte
+	var err error
+	if hidContext, ok := hid.(IHidDeviceContext); ok {
+		response, err = hidContext.ExchangeContext(ctx, apdu)
+	} else if ctx.Done() == nil {
+		response, err = hid.Exchange(apdu)
+	} else {
+		type result struct {
+			response []byte
+			err      error
+		}
+		done := make(chan result, 1)
+		finished := make(chan struct{})
+		go func() {
+			defer close(finished)
+			response, err := hid.Exchange(apdu)
+			done <- result{response: response, err: err}
+		}()
+		select {
+		case r := <-done:
+			response, err = r.response, r.err
+		case <-ctx.Done():
+			// The device still processes the command, its response is dropped
+			exchanger.mutex.Lock()
+			exchanger.abandoned = finished
+			exchanger.mutex.Unlock()
+			return nil, &CanceledError{Err: ctx.Err()}
+		}
+	}
+
+	if err != nil && ctx.Err() != nil {
+		return nil, &CanceledError{Err: ctx.Err()}
+	}
+	return response, err
+}
+
+// Wrapper on top of ExchangeContext to simplify work of the implementation.
+// param ctx  Context
 // param cla  Application Identifier
 // param ins  Instruction ID
 // param p1   Parameter 1
 // param p2   Parameter 2
 // param data Payload
 // return {[]byte} Response data
-// return {error} Error value.
-func (device *Ledger) send(cla, ins, p1, p2 byte, data []byte) ([]byte, error) {
-	if len(data) >= 256 {
-		return nil, fmt.Errorf("DataLengthTooBig: data.length exceed 256 bytes limit. Got: %v", len(data))
-	}
-	buffer := make([]byte, 5+len(data))
-	buffer[0] = cla
-	buffer[1] = ins
-	buffer[2] = p1
-	buffer[3] = p2
-	buffer[4] = byte(len(data))
-	copy(buffer[5:], data)
-	response, err := device.hid.Exchange(buffer)
-	if err == nil {
-		response, status := stripRetcodeFromResponse(response)
-		if status != 0x9000 {
-			if status == 0x6E00 {
-				return response, fmt.Errorf("Spacemesh app is not launched")
-			}
-			if status == 0x6E05 {
-				return response, fmt.Errorf("Request Error 0x6E05: P1, P2 or payload is invalid")
-			}
-			if status == 0x6E06 {
-				return response, fmt.Errorf("Request Error 0x6E06: Request is not valid in the context of previous calls")
-			}
-			if status == 0x6E07 {
-				return response, fmt.Errorf("Request Error 0x6E07: Some part of request data is invalid")
-			}
-			if status == 0x6E09 {
-				return response, fmt.Errorf("Request Error 0x6E09: User rejected the action")
-			}
-			if status == 0x6E11 {
-				return response, fmt.Errorf("Request Error 0x6E11: Pin screen")
-			}
-			return response, fmt.Errorf("Request Error: %x", status)
-		}
-		return response, nil
+// return {error} Error value, StatusError if the device returned error status word.
+func (device *Ledger) send(ctx context.Context, cla, ins, p1, p2 byte, data []byte) ([]byte, error) {
+	response, err := device.ExchangeContext(ctx, Command{CLA: cla, INS: ins, P1: p1, P2: p2, Data: data})
+	if err != nil {
+		return nil, err
 	}
-
-	return nil, err
+	return response.Data, response.Err()
 }
 
 // GetVersion Returns an object containing the app version.
@@ -153,7 +222,12 @@ func (device *Ledger) send(cla, ins, p1, p2 byte, data []byte) ([]byte, error) {
 //		fmt.Printf("version: %+v\n", version)
 //	}
 func (device *Ledger) GetVersion() (*Version, error) {
-	response, err := device.send(cCLA, cInsGetVersion, cP1Unused, cP2Unused, []byte{})
+	return device.GetVersionContext(context.Background())
+}
+
+// GetVersionContext Same as GetVersion, returns CanceledError when the context is done.
+func (device *Ledger) GetVersionContext(ctx context.Context) (*Version, error) {
+	response, err := device.send(ctx, cCLA, cInsGetVersion, cP1Unused, cP2Unused, []byte{})
 	if err != nil {
 		return nil, err
 	}
@@ -170,7 +244,7 @@ func (device *Ledger) GetVersion() (*Version, error) {
 
 // GetExtendedPublicKey Get a public key from the specified BIP 32 path.
 //
-// param {BipPath} path The BIP 32 path indexes. Path must begin with `44'/540'/n'`, and shuld be 5 indexes long.
+// param {BipPath} path The BIP 32 path indexes. Path must be `44'/540'/a'/c/i'`, see ValidateSpacemeshPath.
 // return {ExtendedPublicKey} The public key with chaincode for the given path.
 // return {error} Error value.
 //
@@ -184,14 +258,25 @@ func (device *Ledger) GetVersion() (*Version, error) {
 //		fmt.Printf("public key: %+v\n", publicKey)
 //	}
 func (device *Ledger) GetExtendedPublicKey(path BipPath) (*ExtendedPublicKey, error) {
+	return device.GetExtendedPublicKeyContext(context.Background(), path)
+}
+
+// GetExtendedPublicKeyContext Same as GetExtendedPublicKey, waiting for the user confirmation
+// is aborted when the context is done and CanceledError is returned.
+// The device keeps displaying the request until the user dismisses it.
+func (device *Ledger) GetExtendedPublicKeyContext(ctx context.Context, path BipPath) (*ExtendedPublicKey, error) {
+	if err := ValidateSpacemeshPath(path); err != nil {
+		return nil, err
+	}
 	data := pathToBytes(path)
-	response, err := device.send(cCLA, cInsGetExtPublicKey, cP1Unused, cP2Unused, data)
+	response, err := device.send(ctx, cCLA, cInsGetExtPublicKey, cP1Unused, cP2Unused, data)
 	if err != nil {
 		return nil, err
 	}
 	if len(response) != (32 + 32) {
 		return nil, fmt.Errorf("Wrong response length: expected 64, got %v", len(response))
 	}
+	device.rememberPublicKey(path, response[:32])
 	return &ExtendedPublicKey{
 		PublicKey: response[:32],
 		ChainCode: response[32:],
@@ -200,7 +285,7 @@ func (device *Ledger) GetExtendedPublicKey(path BipPath) (*ExtendedPublicKey, er
 
 // GetAddress Gets an address from the specified BIP 32 path.
 //
-// param {BipPath} path The BIP 32 path indexes. Path must begin with `44'/540'/0'/0/i`
+// param {BipPath} path The BIP 32 path indexes. Path must be `44'/540'/a'/c/i'`, see ValidateSpacemeshPath.
 // return {[]byte} The address for the given path.
 // return {error} Error value.
 //
@@ -214,8 +299,16 @@ func (device *Ledger) GetExtendedPublicKey(path BipPath) (*ExtendedPublicKey, er
 //		fmt.Printf("address: %+v\n", address)
 //	}
 func (device *Ledger) GetAddress(path BipPath) ([]byte, error) {
+	return device.GetAddressContext(context.Background(), path)
+}
+
+// GetAddressContext Same as GetAddress, returns CanceledError when the context is done.
+func (device *Ledger) GetAddressContext(ctx context.Context, path BipPath) ([]byte, error) {
+	if err := ValidateSpacemeshPath(path); err != nil {
+		return nil, err
+	}
 	data := pathToBytes(path)
-	response, err := device.send(cCLA, cInsGetAddress, cP1Return, cP2Unused, data)
+	response, err := device.send(ctx, cCLA, cInsGetAddress, cP1Return, cP2Unused, data)
 	if err != nil {
 		return nil, err
 	}
@@ -227,7 +320,7 @@ func (device *Ledger) GetAddress(path BipPath) ([]byte, error) {
 
 // ShowAddress Show an address from the specified BIP 32 path for verify.
 //
-// param {BipPath} indexes The path indexes. Path must begin with `44'/540'/0'/0/i`
+// param {BipPath} indexes The path indexes. Path must be `44'/540'/a'/c/i'`, see ValidateSpacemeshPath.
 // return {error} Error value.
 //
 // example
@@ -240,8 +333,16 @@ func (device *Ledger) GetAddress(path BipPath) ([]byte, error) {
 //		fmt.Printf("show address: OK\n")
 //	}
 func (device *Ledger) ShowAddress(path BipPath) error {
+	return device.ShowAddressContext(context.Background(), path)
+}
+
+// ShowAddressContext Same as ShowAddress, returns CanceledError when the context is done.
+func (device *Ledger) ShowAddressContext(ctx context.Context, path BipPath) error {
+	if err := ValidateSpacemeshPath(path); err != nil {
+		return err
+	}
 	data := pathToBytes(path)
-	response, err := device.send(cCLA, cInsGetAddress, cP1Display, cP2Unused, data)
+	response, err := device.send(ctx, cCLA, cInsGetAddress, cP1Display, cP2Unused, data)
 	if err != nil {
 		return err
 	}
@@ -254,48 +355,58 @@ func (device *Ledger) ShowAddress(path BipPath) error {
 
 // SignTx Sign a transaction by the specified BIP 32 path account address.
 //
-// param {BipPath} path The BIP 32 path indexes. Path must begin with `44'/540'/0'/0/i`
-// param {[]byte} tx The XDR encoded transaction data, include transaction type
-// return {[]byte} Signed transaction.
-// return {error} Error value
+// param {BipPath} path The BIP 32 path indexes. Path must be `44'/540'/a'/c/i'`, see ValidateSpacemeshPath.
+// param {[]byte} tx The XDR encoded transaction data, include transaction type.
+// Malformed data is rejected by DecodeTx before anything is sent to the device.
+// return {*SignedTx} Signed transaction.
+// return {error} Error value, *VerificationError if SetVerifySignatures is enabled and the signature is rejected.
 //
 // example
-// tx := make([]byte, 0)
-// var bin []byte
-// bin, _ = hex.DecodeString("0000000000000000000000000000000000000000000000000000000000000000") // network id
-// tx = append(tx, bin...)
-// tx = append(tx, 0) // coin transaction with ed
-// tx = append(tx, uint64_to_buf(1)...) // nonce
-// bin, _ = hex.DecodeString("0000000000000000000000000000000000000000") // recepient
-// tx = append(tx, bin...)
-// tx = append(tx, uint64_to_buf(1000000)...) // gas limit
-// tx = append(tx, uint64_to_buf(1000)...) // gas price
-// tx = append(tx, uint64_to_buf(1000000000000)...) // amount
-// tx = append(tx, publicKey.PublicKey...)
 //
-// response, err := device.SignTx(ledger.StringToPath("44'/540'/0'/0/0'"), tx)
+//	tx, err := (&ledger.CoinTx{TxHeader: ledger.TxHeader{
+//		NetworkID: networkID,
+//		Nonce:     1,
+//		Recipient: recipient,
+//		GasLimit:  1000000,
+//		GasPrice:  1000,
+//		Amount:    1000000000000,
+//		PublicKey: publicKey.PublicKey,
+//	}}).Encode()
+//
+// signedTx, err := device.SignTx(ledger.StringToPath("44'/540'/0'/0/0'"), tx)
 //
 //	if err != nil {
 //		fmt.Printf("Verify coin tx ERROR: %v\n", err)
 //	} else {
 //
-//		hash := sha512.Sum512(tx)
-//		fmt.Printf("Verify coin tx: %v\n", ed25519.Verify(publicKey.PublicKey, hash[:], response[1:65]))
+//		fmt.Printf("Verify coin tx: %v\n", signedTx.Verify())
 //	}
-func (device *Ledger) SignTx(path BipPath, tx []byte) ([]byte, error) {
+func (device *Ledger) SignTx(path BipPath, tx []byte) (*SignedTx, error) {
+	return device.SignTxContext(context.Background(), path, tx)
+}
+
+// SignTxContext Same as SignTx, the remaining chunks are not sent and waiting for
+// the user confirmation is aborted when the context is done, CanceledError is returned.
+func (device *Ledger) SignTxContext(ctx context.Context, path BipPath, tx []byte) (*SignedTx, error) {
+	if err := ValidateSpacemeshPath(path); err != nil {
+		return nil, err
+	}
+	if _, err := DecodeTx(tx); err != nil {
+		return nil, err
+	}
 	data := pathToBytes(path)
 	data = append(data, tx...)
 	var response []byte
 	var err error
 
 	if len(data) <= cMaxPacketLength {
-		response, err = device.send(cCLA, cInsSignTx, cP1HasHeader|cP1IsLast, cP2Unused, data)
+		response, err = device.send(ctx, cCLA, cInsSignTx, cP1HasHeader|cP1IsLast, cP2Unused, data)
 	} else {
 		dataSize := len(data)
 		chunkSize := cMaxPacketLength
 		offset := 0
 		// Send tx header + tx data
-		response, err = device.send(cCLA, cInsSignTx, cP1HasHeader|cP1HasData, cP2Unused, data[offset:offset+chunkSize])
+		response, err = device.send(ctx, cCLA, cInsSignTx, cP1HasHeader|cP1HasData, cP2Unused, data[offset:offset+chunkSize])
 		if err != nil {
 			return nil, err
 		}
@@ -306,7 +417,7 @@ func (device *Ledger) SignTx(path BipPath, tx []byte) ([]byte, error) {
 		offset += chunkSize
 		// Send tx data
 		for dataSize > cMaxPacketLength {
-			response, err = device.send(cCLA, cInsSignTx, cP1HasData, cP2Unused, data[offset:offset+chunkSize])
+			response, err = device.send(ctx, cCLA, cInsSignTx, cP1HasData, cP2Unused, data[offset:offset+chunkSize])
 			if err != nil {
 				return nil, err
 			}
@@ -316,22 +427,27 @@ func (device *Ledger) SignTx(path BipPath, tx []byte) ([]byte, error) {
 			dataSize -= chunkSize
 			offset += chunkSize
 		}
-		response, err = device.send(cCLA, cInsSignTx, cP1IsLast, cP2Unused, data[offset:])
+		response, err = device.send(ctx, cCLA, cInsSignTx, cP1IsLast, cP2Unused, data[offset:])
 	}
 
 	if err != nil {
 		return nil, err
 	}
 
-	if len(response) != (64 + 32) {
-		return nil, fmt.Errorf("Wrong response length: expected 96, got %v", len(response))
+	if len(response) != SignatureSize+PublicKeySize {
+		return nil, fmt.Errorf("Wrong response length: expected %v, got %v", SignatureSize+PublicKeySize, len(response))
 	}
 
-	result := make([]byte, 64+32+1)
-	result[0] = tx[33]
-	copy(result[1:], response)
-
-	return result, nil
+	signedTx := &SignedTx{
+		Type:      TxType(tx[cTxTypeOffset]),
+		Signature: append([]byte{}, response[:SignatureSize]...),
+		PublicKey: append([]byte{}, response[SignatureSize:]...),
+		Tx:        append([]byte{}, tx...),
+	}
+	if err := device.verify(path, signedTx); err != nil {
+		return nil, err
+	}
+	return signedTx, nil
 }
 
 // NewLedger Create new Ledger
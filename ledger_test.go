@@ -8,24 +8,10 @@ import (
 	"testing"
 )
 
-// Return string representation of transaction type
-func getTxTypeString(txType byte) string {
-	switch txType {
-	case 0:
-		return "COIN ED"
-	case 2:
-		return "EXEC APP ED"
-	case 4:
-		return "SPAWN APP ED"
-	default:
-		return "UNWNOWN"
-	}
-}
-
 // Display transaction info
 func printTxInfo(txInfo *txInfo) {
 	fmt.Printf("Check tx params on ledger:\n")
-	fmt.Printf("\tTx type: %s\n", getTxTypeString(txInfo.Type))
+	fmt.Printf("\tTx type: %s\n", TxType(txInfo.Type))
 	fmt.Printf("\tSend SMH: %v\n", float64(txInfo.Amount)/1000000000000.0)
 	fmt.Printf("\tTo address: %x\n", txInfo.To)
 	fmt.Printf("\tMax Tx Fee: %v\n", float64(txInfo.GasLimit*txInfo.GasPrice)/1000000000000.0)
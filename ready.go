@@ -0,0 +1,195 @@
+package ledger
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	// First delay between the readiness checks
+	cReadyMinBackoff = 100 * time.Millisecond
+	// Max delay between the readiness checks
+	cReadyMaxBackoff = 2 * time.Second
+)
+
+// ReadyState State of the device reported by WaitForReady
+type ReadyState int
+
+const (
+	// ReadyStateLocked Device is locked, the pin screen is shown
+	ReadyStateLocked ReadyState = iota
+	// ReadyStateWrongApp Dashboard or another app is running
+	ReadyStateWrongApp
+	// ReadyStateBusy Device is processing another request
+	ReadyStateBusy
+	// ReadyStateDisconnected Device does not respond, it is reopened
+	ReadyStateDisconnected
+	// ReadyStateReady Spacemesh app is running
+	ReadyStateReady
+)
+
+// String State name
+func (state ReadyState) String() string {
+	switch state {
+	case ReadyStateLocked:
+		return "locked"
+	case ReadyStateWrongApp:
+		return "wrong app"
+	case ReadyStateBusy:
+		return "busy"
+	case ReadyStateDisconnected:
+		return "disconnected"
+	case ReadyStateReady:
+		return "ready"
+	default:
+		return "unknown"
+	}
+}
+
+// ReadyEvent Progress event of WaitForReady
+type ReadyEvent struct {
+	// Device state
+	State ReadyState
+	// Running app for ReadyStateWrongApp, nil if it is not known
+	App *AppInfo
+	// Error returned by the device, nil for ReadyStateReady
+	Err error
+	// Error of reopening the device for ReadyStateDisconnected, nil if it was reopened
+	ReconnectErr error
+}
+
+// WaitForReady Poll the device with GetVersion until the Spacemesh app responds.
+// The device is reopened if it stops responding, e.g. when it is re-enumerated
+// with another USB product id after the app is opened. The disconnected state is reported
+// again when the reconnection error changes. Waiting stops with ErrMultipleDevices
+// if several connected devices may be the re-enumerated one.
+//
+// param {context.Context} ctx Context to stop waiting.
+// param {func(ReadyEvent)} progress Called when the device state changes, may be nil.
+// return {*Version} Version of the Spacemesh app.
+// return {error} Error value, CanceledError when the context is done.
+//
+// example
+//
+//	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+//	defer cancel()
+//	version, err := device.WaitForReady(ctx, func(event ledger.ReadyEvent) {
+//		switch event.State {
+//		case ledger.ReadyStateLocked:
+//			fmt.Printf("unlock the device\n")
+//		case ledger.ReadyStateWrongApp:
+//			fmt.Printf("open Spacemesh app\n")
+//		}
+//	})
+func (device *Ledger) WaitForReady(ctx context.Context, progress func(event ReadyEvent)) (*Version, error) {
+	backoff := cReadyMinBackoff
+	lastState := ReadyState(-1)
+	lastReconnectErr := ""
+	report := func(event ReadyEvent) {
+		reconnectErr := ""
+		if event.ReconnectErr != nil {
+			reconnectErr = event.ReconnectErr.Error()
+		}
+		if progress != nil && (event.State != lastState || reconnectErr != lastReconnectErr) {
+			progress(event)
+		}
+		lastState, lastReconnectErr = event.State, reconnectErr
+	}
+
+	for {
+		version, err := device.GetVersionContext(ctx)
+		if err == nil {
+			report(ReadyEvent{State: ReadyStateReady})
+			return version, nil
+		}
+		var canceled *CanceledError
+		if errors.As(err, &canceled) {
+			return nil, err
+		}
+
+		var statusErr *StatusError
+		switch {
+		case errors.Is(err, ErrDeviceLocked):
+			report(ReadyEvent{State: ReadyStateLocked, Err: err})
+		case errors.Is(err, ErrAppNotOpen):
+			event := ReadyEvent{State: ReadyStateWrongApp, Err: err}
+			if info, infoErr := device.GetAppInfoContext(ctx); infoErr == nil {
+				event.App = info
+			}
+			report(event)
+		case errors.Is(err, ErrStillInCall):
+			report(ReadyEvent{State: ReadyStateBusy, Err: err})
+		case errors.As(err, &statusErr):
+			return nil, err
+		default:
+			reconnectErr := device.reconnect()
+			report(ReadyEvent{State: ReadyStateDisconnected, Err: err, ReconnectErr: reconnectErr})
+			// Waiting does not help, the user must disconnect the other devices
+			if errors.Is(reconnectErr, ErrMultipleDevices) {
+				return nil, reconnectErr
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, &CanceledError{Err: ctx.Err()}
+		case <-time.After(backoff):
+		}
+		if backoff *= 2; backoff > cReadyMaxBackoff {
+			backoff = cReadyMaxBackoff
+		}
+	}
+}
+
+// Reopen the device, look for the same device among the connected devices
+// if it was re-enumerated, e.g. with another product id. The device at the same path is
+// preferred, otherwise the only device with the same model and serial number is used.
+// return {error} Error value, ErrMultipleDevices if several devices may be the same one.
+func (device *Ledger) reconnect() error {
+	info := *device.hid.GetInfo()
+	device.hid.Close()
+	err := device.hid.Open()
+	if err == nil {
+		return nil
+	}
+	enumerator := device.enumerator
+	if enumerator == nil {
+		enumerator = &HidEnumerator{}
+	}
+	candidates, enumerateErr := enumerator.Enumerate()
+	if enumerateErr != nil {
+		return err
+	}
+	var samePath *Ledger
+	sameSerial := make([]*Ledger, 0)
+	for _, candidate := range candidates {
+		candidateInfo := candidate.hid.GetInfo()
+		if candidateInfo.VendorID != info.VendorID {
+			continue
+		}
+		if info.Path != "" && candidateInfo.Path == info.Path {
+			samePath = candidate
+		} else if maybeSameDevice(&info, candidateInfo) {
+			sameSerial = append(sameSerial, candidate)
+		}
+	}
+	switch {
+	case samePath != nil:
+		if openErr := samePath.hid.Open(); openErr != nil {
+			return openErr
+		}
+		device.setHid(samePath.hid)
+		return nil
+	case len(sameSerial) > 1:
+		return fmt.Errorf("%w: %v devices with serial number %q", ErrMultipleDevices, len(sameSerial), info.SerialNumber)
+	case len(sameSerial) == 1:
+		if openErr := sameSerial[0].hid.Open(); openErr != nil {
+			return openErr
+		}
+		device.setHid(sameSerial[0].hid)
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,201 @@
+package ledger
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// Emulator answering with the status word to the first requests
+type lockedDevice struct {
+	*Emulator
+	status   []byte
+	requests int
+}
+
+func (device *lockedDevice) Exchange(apdu []byte) ([]byte, error) {
+	if device.requests > 0 {
+		device.requests--
+		return device.status, nil
+	}
+	return device.Emulator.Exchange(apdu)
+}
+
+// Device which was unplugged
+type unpluggedDevice struct {
+	Info HidDeviceInfo
+}
+
+func (device *unpluggedDevice) Open() error {
+	return fmt.Errorf("Device is not connected")
+}
+
+func (device *unpluggedDevice) Close() {
+}
+
+func (device *unpluggedDevice) GetInfo() *HidDeviceInfo {
+	return &device.Info
+}
+
+func (device *unpluggedDevice) Exchange(apdu []byte) ([]byte, error) {
+	return nil, fmt.Errorf("Device is not connected")
+}
+
+// Collect states reported by WaitForReady
+func waitForReady(ctx context.Context, device *Ledger, progress func(event ReadyEvent)) ([]ReadyState, error) {
+	var states []ReadyState
+	_, err := device.WaitForReady(ctx, func(event ReadyEvent) {
+		states = append(states, event.State)
+		if progress != nil {
+			progress(event)
+		}
+	})
+	return states, err
+}
+
+func TestWaitForReady(t *testing.T) {
+	ctx := context.Background()
+	locked := &lockedDevice{Emulator: newTestEmulator(t), status: []byte{0x6e, 0x11}, requests: 2}
+	states, err := waitForReady(ctx, NewLedger(locked), nil)
+	if err != nil || !reflect.DeepEqual(states, []ReadyState{ReadyStateLocked, ReadyStateReady}) {
+		t.Fatalf("WRONG locked device states %v: %v\n", states, err)
+	}
+
+	emulator := newTestEmulator(t)
+	emulator.Dashboard = true
+	device := NewLedger(emulator)
+	states, err = waitForReady(ctx, device, func(event ReadyEvent) {
+		if event.State == ReadyStateWrongApp {
+			if event.App == nil || !event.App.IsDashboard() {
+				t.Errorf("WRONG running app %+v\n", event.App)
+			}
+			if err := device.OpenApp(SpacemeshAppName); err != nil {
+				t.Errorf("open app ERROR: %v\n", err)
+			}
+		}
+	})
+	if err != nil || !reflect.DeepEqual(states, []ReadyState{ReadyStateWrongApp, ReadyStateReady}) {
+		t.Fatalf("WRONG dashboard states %v: %v\n", states, err)
+	}
+
+	rejected := &lockedDevice{Emulator: newTestEmulator(t), status: []byte{0x6e, 0x03}, requests: 1}
+	if _, err := NewLedger(rejected).WaitForReady(ctx, nil); !errors.Is(err, ErrUnknownInstruction) {
+		t.Fatalf("WRONG terminal error: %v\n", err)
+	}
+
+	timeout, cancel := context.WithTimeout(ctx, 150*time.Millisecond)
+	defer cancel()
+	locked = &lockedDevice{Emulator: newTestEmulator(t), status: []byte{0x55, 0x15}, requests: 100}
+	var canceled *CanceledError
+	if _, err := NewLedger(locked).WaitForReady(timeout, nil); !errors.As(err, &canceled) {
+		t.Fatalf("WRONG cancel error: %v\n", err)
+	}
+}
+
+func TestWaitForReadyReenumeration(t *testing.T) {
+	other := newTestEmulator(t)
+	other.Info.Path = "c"
+	other.Info.ProductID = 0x4015
+	reenumerated := newTestEmulator(t)
+	reenumerated.Info.Path = "b"
+	reenumerated.Info.ProductID = 0x1015
+	device := NewLedger(&unpluggedDevice{Info: HidDeviceInfo{Path: "a", VendorID: LedgerUSBVendorID, ProductID: 0x1011, SerialNumber: "0001"}})
+	device.enumerator = EnumeratorFunc(func() ([]*Ledger, error) {
+		return []*Ledger{NewLedger(other), NewLedger(reenumerated)}, nil
+	})
+	states, err := waitForReady(context.Background(), device, nil)
+	if err != nil || !reflect.DeepEqual(states, []ReadyState{ReadyStateDisconnected, ReadyStateReady}) {
+		t.Fatalf("WRONG states %v: %v\n", states, err)
+	}
+	if device.GetHidInfo().ProductID != 0x1015 {
+		t.Fatalf("WRONG device %+v\n", device.GetHidInfo())
+	}
+}
+
+func TestReconnect(t *testing.T) {
+	unplugged := HidDeviceInfo{Path: "a", VendorID: LedgerUSBVendorID, ProductID: 0x1011, SerialNumber: "0001"}
+	newDevice := func(path string, productID uint16) *Emulator {
+		emulator := newTestEmulator(t)
+		emulator.Info.Path = path
+		emulator.Info.ProductID = productID
+		return emulator
+	}
+	reconnect := func(candidates ...*Emulator) (*Ledger, error) {
+		device := NewLedger(&unpluggedDevice{Info: unplugged})
+		device.enumerator = EnumeratorFunc(func() ([]*Ledger, error) {
+			devices := make([]*Ledger, 0, len(candidates))
+			for _, candidate := range candidates {
+				devices = append(devices, NewLedger(candidate))
+			}
+			return devices, nil
+		})
+		return device, device.reconnect()
+	}
+
+	// Another Nano S with the same serial number, the device at the same path is preferred
+	device, err := reconnect(newDevice("b", 0x1011), newDevice("a", 0x1015))
+	if err != nil || device.GetHidInfo().Path != "a" {
+		t.Fatalf("WRONG reconnected device %+v: %v\n", device.GetHidInfo(), err)
+	}
+	// Several devices of the same model and serial number
+	device, err = reconnect(newDevice("b", 0x1011), newDevice("c", 0x1015))
+	if !errors.Is(err, ErrMultipleDevices) || device.GetHidInfo().Path != "a" {
+		t.Fatalf("WRONG ambiguous reconnect %+v: %v\n", device.GetHidInfo(), err)
+	}
+	// Legacy product ids of Nano X and Nano S Plus do not match
+	device, err = reconnect(newDevice("b", 0x0004), newDevice("c", 0x0005))
+	if err == nil || device.GetHidInfo().Path != "a" {
+		t.Fatalf("WRONG reconnect to other model %+v: %v\n", device.GetHidInfo(), err)
+	}
+	device, err = reconnect(newDevice("c", 0x0001))
+	if err != nil || device.GetHidInfo().Path != "c" {
+		t.Fatalf("WRONG reconnect to legacy product id %+v: %v\n", device.GetHidInfo(), err)
+	}
+}
+
+func TestWaitForReadyMultipleDevices(t *testing.T) {
+	first := newTestEmulator(t)
+	first.Info.Path = "b"
+	first.Info.ProductID = 0x1015
+	second := newTestEmulator(t)
+	second.Info.Path = "c"
+	second.Info.ProductID = 0x1015
+	device := NewLedger(&unpluggedDevice{Info: HidDeviceInfo{Path: "a", VendorID: LedgerUSBVendorID, ProductID: 0x1011, SerialNumber: "0001"}})
+	device.enumerator = EnumeratorFunc(func() ([]*Ledger, error) {
+		return []*Ledger{NewLedger(first), NewLedger(second)}, nil
+	})
+
+	var events []ReadyEvent
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err := device.WaitForReady(ctx, func(event ReadyEvent) {
+		events = append(events, event)
+	})
+	if !errors.Is(err, ErrMultipleDevices) {
+		t.Fatalf("WRONG error: %v\n", err)
+	}
+	if len(events) != 1 || events[0].State != ReadyStateDisconnected || !errors.Is(events[0].ReconnectErr, ErrMultipleDevices) {
+		t.Fatalf("WRONG events: %+v\n", events)
+	}
+
+	// The reason of the failed reconnection is reported when it changes
+	events = nil
+	device.enumerator = EnumeratorFunc(func() ([]*Ledger, error) {
+		return nil, nil
+	})
+	ctx, cancel = context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+	_, err = device.WaitForReady(ctx, func(event ReadyEvent) {
+		events = append(events, event)
+	})
+	var canceled *CanceledError
+	if !errors.As(err, &canceled) {
+		t.Fatalf("WRONG cancel error: %v\n", err)
+	}
+	if len(events) != 1 || events[0].ReconnectErr == nil || events[0].ReconnectErr.Error() != "Device is not connected" {
+		t.Fatalf("WRONG events: %+v\n", events)
+	}
+}
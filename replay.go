@@ -0,0 +1,176 @@
+package ledger
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// ErrReplayEnd The replayed session has no more exchanges
+var ErrReplayEnd = errors.New("Replay session has no more exchanges")
+
+// ReplayMismatchError Command differs from the recorded one
+type ReplayMismatchError struct {
+	// Number of the exchange in the session, starting at 1
+	Seq int
+	// Recorded command
+	Expected []byte
+	// Replayed command
+	Got []byte
+}
+
+// Error Error message
+func (e *ReplayMismatchError) Error() string {
+	return fmt.Sprintf("Replay diverged at exchange %v: expected %x, got %x", e.Seq, e.Expected, e.Got)
+}
+
+// Recorded exchange
+type replayExchange struct {
+	seq      int
+	command  []byte
+	response []byte
+	err      error
+}
+
+// ReplayDevice HID device answering commands with the responses of recorded session
+type ReplayDevice struct {
+	info      HidDeviceInfo
+	exchanges []replayExchange
+	next      int
+	err       error
+	mutex     sync.Mutex
+}
+
+// NewRecordingDevice Wrap the device to record its session as JSON lines, see NewReplayDevice.
+//
+// param {IHidDevice} device Recorded device.
+// param {io.Writer} writer Destination of the session.
+// return {*TracingDevice} Wrapped device.
+//
+// example
+// file, _ := os.Create("test/device-session.jsonl")
+// defer file.Close()
+// device := ledger.NewLedger(ledger.NewRecordingDevice(hid, file))
+func NewRecordingDevice(device IHidDevice, writer io.Writer) *TracingDevice {
+	return NewTracingDevice(device, NewJSONTraceSink(writer))
+}
+
+// NewReplayDevice Load session recorded by NewRecordingDevice or JSONTraceSink.
+//
+// param {io.Reader} reader Recorded session, JSON lines of trace events.
+// return {*ReplayDevice} Device replaying the session.
+// return {error} Error value.
+//
+// example
+// file, _ := os.Open("test/device-session.jsonl")
+// defer file.Close()
+// replay, err := ledger.NewReplayDevice(file)
+//
+//	if err == nil {
+//		device := ledger.NewLedger(replay)
+//		...
+//		err = replay.Done()
+//	}
+func NewReplayDevice(reader io.Reader) (*ReplayDevice, error) {
+	device := &ReplayDevice{info: HidDeviceInfo{Path: "replay"}}
+	pending := make(map[int]int)
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var event TraceEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			return nil, fmt.Errorf("Invalid replay session line %v: %w", line, err)
+		}
+		switch event.Direction {
+		case TraceSend:
+			if _, ok := pending[event.Seq]; ok {
+				return nil, fmt.Errorf("Invalid replay session line %v: exchange %v sent twice", line, event.Seq)
+			}
+			if event.Device != "" {
+				device.info.Path = event.Device
+			}
+			pending[event.Seq] = len(device.exchanges)
+			device.exchanges = append(device.exchanges, replayExchange{seq: event.Seq, command: event.Data})
+		case TraceReceive:
+			index, ok := pending[event.Seq]
+			if !ok {
+				return nil, fmt.Errorf("Invalid replay session line %v: exchange %v received before sent", line, event.Seq)
+			}
+			delete(pending, event.Seq)
+			device.exchanges[index].response = event.Data
+			device.exchanges[index].err = event.Err
+			if event.Data == nil && event.Err == nil {
+				device.exchanges[index].err = fmt.Errorf("Exchange %v failed without error", event.Seq)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	for seq, index := range pending {
+		// The device did not answer while recording
+		device.exchanges[index].err = fmt.Errorf("No response recorded for exchange %v", seq)
+	}
+	return device, nil
+}
+
+// Open Open replayed device, does nothing
+func (device *ReplayDevice) Open() error {
+	return nil
+}
+
+// Close Close replayed device, does nothing
+func (device *ReplayDevice) Close() {
+}
+
+// GetInfo Get HID device info, only the path of the recorded device is known
+func (device *ReplayDevice) GetInfo() *HidDeviceInfo {
+	return &device.info
+}
+
+// Exchange Return the next recorded response if the command matches the recorded one.
+// The first mismatch fails all following exchanges.
+// return {error} Error value, ReplayMismatchError if the command differs, ErrReplayEnd after the last exchange
+// or the recorded error.
+func (device *ReplayDevice) Exchange(apdu []byte) ([]byte, error) {
+	device.mutex.Lock()
+	defer device.mutex.Unlock()
+	if device.err != nil {
+		return nil, device.err
+	}
+	if device.next >= len(device.exchanges) {
+		device.err = fmt.Errorf("%w: got %x", ErrReplayEnd, apdu)
+		return nil, device.err
+	}
+	exchange := device.exchanges[device.next]
+	if !bytes.Equal(apdu, exchange.command) {
+		device.err = &ReplayMismatchError{Seq: exchange.seq, Expected: exchange.command, Got: append([]byte{}, apdu...)}
+		return nil, device.err
+	}
+	device.next++
+	if exchange.err != nil {
+		return nil, exchange.err
+	}
+	return append([]byte{}, exchange.response...), nil
+}
+
+// Done Check that the whole session was replayed without mismatch
+// return {error} Error value, the first exchange error or the number of remaining exchanges.
+func (device *ReplayDevice) Done() error {
+	device.mutex.Lock()
+	defer device.mutex.Unlock()
+	if device.err != nil {
+		return device.err
+	}
+	if remaining := len(device.exchanges) - device.next; remaining > 0 {
+		return fmt.Errorf("Replay session not finished: %v of %v exchanges remaining", remaining, len(device.exchanges))
+	}
+	return nil
+}
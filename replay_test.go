@@ -0,0 +1,140 @@
+package ledger
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+)
+
+// Session of test/emulator-session.jsonl, recorded with the Emulator and the test seed.
+// The file checks the replay against the Emulator protocol, not against the device.
+func runReplaySession(t *testing.T, device *Ledger) {
+	path := StringToPath("44'/540'/0'/0/0'")
+	version, err := device.GetVersion()
+	if err != nil {
+		t.Fatalf("get version ERROR: %v\n", err)
+	}
+	if version.Major != 0 || version.Minor != 0 || version.Patch != 4 {
+		t.Fatalf("WRONG version: %+v\n", version)
+	}
+	publicKey, err := device.GetExtendedPublicKey(path)
+	if err != nil {
+		t.Fatalf("get public key ERROR: %v\n", err)
+	}
+	if hex.EncodeToString(publicKey.PublicKey) != testPublicKey {
+		t.Fatalf("WRONG public key: %x\n", publicKey.PublicKey)
+	}
+	for _, fileName := range []string{"coin.tx.json", "app.tx.json"} {
+		txInfo, err := loadTxInfo(fileName)
+		if err != nil {
+			t.Fatalf("load tx ERROR: %v\n", err)
+		}
+		txInfo.PublicKey = publicKey.PublicKey
+		signedTx, err := device.SignTx(path, createTx(txInfo))
+		if err != nil {
+			t.Fatalf("sign %v ERROR: %v\n", fileName, err)
+		}
+		if !signedTx.Verify() || !bytes.Equal(signedTx.PublicKey, publicKey.PublicKey) {
+			t.Fatalf("WRONG signed %v: %x\n", fileName, signedTx.Bytes())
+		}
+	}
+}
+
+func TestReplayEmulatorSession(t *testing.T) {
+	file, err := os.Open("test/emulator-session.jsonl")
+	if err != nil {
+		t.Fatalf("open session ERROR: %v\n", err)
+	}
+	defer file.Close()
+	replay, err := NewReplayDevice(file)
+	if err != nil {
+		t.Fatalf("load session ERROR: %v\n", err)
+	}
+	device := NewLedger(replay)
+	device.SetVerifySignatures(true)
+	runReplaySession(t, device)
+	if err := replay.Done(); err != nil {
+		t.Fatalf("replay ERROR: %v\n", err)
+	}
+}
+
+func TestRecordReplay(t *testing.T) {
+	var session bytes.Buffer
+	runReplaySession(t, NewLedger(NewRecordingDevice(newTestEmulator(t), &session)))
+	recorded := session.String()
+
+	replay, err := NewReplayDevice(strings.NewReader(recorded))
+	if err != nil {
+		t.Fatalf("load session ERROR: %v\n", err)
+	}
+	device := NewLedger(replay)
+	if replay.GetInfo().Path != "emulator" {
+		t.Fatalf("WRONG device path: %v\n", replay.GetInfo().Path)
+	}
+	runReplaySession(t, device)
+	if err := replay.Done(); err != nil {
+		t.Fatalf("replay ERROR: %v\n", err)
+	}
+	if _, err := device.GetVersion(); !errors.Is(err, ErrReplayEnd) {
+		t.Fatalf("WRONG error after the end: %v\n", err)
+	}
+	if err := replay.Done(); !errors.Is(err, ErrReplayEnd) {
+		t.Fatalf("WRONG done error: %v\n", err)
+	}
+
+	// Commands differing from the recorded ones fail the replay
+	replay, _ = NewReplayDevice(strings.NewReader(recorded))
+	device = NewLedger(replay)
+	if err := replay.Done(); err == nil || !strings.Contains(err.Error(), "remaining") {
+		t.Fatalf("WRONG done error: %v\n", err)
+	}
+	if _, err := device.GetVersion(); err != nil {
+		t.Fatalf("get version ERROR: %v\n", err)
+	}
+	_, err = device.GetExtendedPublicKey(StringToPath("44'/540'/1'/0/0'"))
+	var mismatch *ReplayMismatchError
+	if !errors.As(err, &mismatch) || mismatch.Seq != 2 || bytes.Equal(mismatch.Expected, mismatch.Got) {
+		t.Fatalf("WRONG mismatch error: %v\n", err)
+	}
+	if _, err := device.GetExtendedPublicKey(StringToPath("44'/540'/0'/0/0'")); !errors.As(err, &mismatch) {
+		t.Fatalf("WRONG error after mismatch: %v\n", err)
+	}
+	if err := replay.Done(); !errors.As(err, &mismatch) {
+		t.Fatalf("WRONG done error: %v\n", err)
+	}
+}
+
+func TestReplayRecordedErrors(t *testing.T) {
+	session := `{"seq":1,"direction":"send","time":"2026-10-16T10:00:00Z","data":"3000000000"}
+{"seq":1,"direction":"receive","time":"2026-10-16T10:00:00Z","duration":"1ms","error":"USB device disconnected"}
+
+{"seq":2,"direction":"send","time":"2026-10-16T10:00:01Z","data":"3000000000"}
+`
+	replay, err := NewReplayDevice(strings.NewReader(session))
+	if err != nil {
+		t.Fatalf("load session ERROR: %v\n", err)
+	}
+	device := NewLedger(replay)
+	if _, err := device.GetVersion(); err == nil || err.Error() != "USB device disconnected" {
+		t.Fatalf("WRONG recorded error: %v\n", err)
+	}
+	if _, err := device.GetVersion(); err == nil || !strings.Contains(err.Error(), "No response recorded") {
+		t.Fatalf("WRONG missing response error: %v\n", err)
+	}
+	if err := replay.Done(); err != nil {
+		t.Fatalf("replay ERROR: %v\n", err)
+	}
+
+	for _, invalid := range []string{
+		`{"seq":1,"direction":"receive","time":"2026-10-16T10:00:00Z","data":"9000"}`,
+		`{"seq":1,"direction":"send","data":"30"}` + "\n" + `{"seq":1,"direction":"send","data":"30"}`,
+		`not json`,
+	} {
+		if _, err := NewReplayDevice(strings.NewReader(invalid)); err == nil {
+			t.Fatalf("load %v NO ERROR\n", invalid)
+		}
+	}
+}
@@ -0,0 +1,327 @@
+package ledger
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// SpeculosDefaultURL Default address of the Speculos REST API
+	SpeculosDefaultURL = "http://127.0.0.1:5000"
+	// SpeculosDefaultAPDUAddress Default address of the Speculos APDU TCP socket
+	SpeculosDefaultAPDUAddress = "127.0.0.1:9999"
+	// SpeculosDefaultTimeout Default timeout of the single APDU exchange,
+	// includes the time the user needs to confirm the action.
+	SpeculosDefaultTimeout = 30 * time.Second
+)
+
+// SpeculosButton Emulated device button
+type SpeculosButton string
+
+const (
+	// SpeculosButtonLeft Left button
+	SpeculosButtonLeft SpeculosButton = "left"
+	// SpeculosButtonRight Right button
+	SpeculosButtonRight SpeculosButton = "right"
+	// SpeculosButtonBoth Both buttons
+	SpeculosButtonBoth SpeculosButton = "both"
+)
+
+// SpeculosEvent Text displayed on the emulated device screen
+type SpeculosEvent struct {
+	Text string `json:"text"`
+	X    int    `json:"x"`
+	Y    int    `json:"y"`
+}
+
+// Speculos Transport over the Speculos REST API
+type Speculos struct {
+	Info HidDeviceInfo
+	// Base URL of the REST API
+	URL string
+	// Timeout of the single APDU exchange, 0 - no timeout
+	Timeout time.Duration
+	// HTTP client used for requests
+	Client *http.Client
+}
+
+// NewSpeculos Create new Speculos REST API transport.
+//
+// param {string} url Base URL of the REST API, e.g. "http://127.0.0.1:5001".
+// return {*Speculos} Speculos transport, implements IHidDevice.
+//
+// example
+// device := ledger.NewLedger(ledger.NewSpeculos("http://127.0.0.1:5001"))
+// version, err := device.GetVersion()
+func NewSpeculos(url string) *Speculos {
+	return &Speculos{
+		Info:    HidDeviceInfo{Path: url},
+		URL:     strings.TrimRight(url, "/"),
+		Timeout: SpeculosDefaultTimeout,
+		Client:  &http.Client{},
+	}
+}
+
+// Open dummy method for Speculos
+func (device *Speculos) Open() error {
+	return nil
+}
+
+// Close dummy method for Speculos
+func (device *Speculos) Close() {
+}
+
+// GetInfo Get Speculos device info
+func (device *Speculos) GetInfo() *HidDeviceInfo {
+	return &device.Info
+}
+
+// Send HTTP request with JSON body and decode JSON response
+func (device *Speculos) post(ctx context.Context, path string, request, response interface{}) error {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, device.URL+path, bytes.NewBuffer(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := device.Client.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		message, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("Speculos error %v: %s", resp.StatusCode, strings.TrimSpace(string(message)))
+	}
+	if response == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(response)
+}
+
+// Exchange Exchange APDU packets with Speculos
+func (device *Speculos) Exchange(apdu []byte) ([]byte, error) {
+	return device.ExchangeContext(context.Background(), apdu)
+}
+
+// ExchangeContext Exchange APDU packets with Speculos, the request is aborted when the context is done.
+func (device *Speculos) ExchangeContext(ctx context.Context, apdu []byte) ([]byte, error) {
+	if device.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, device.Timeout)
+		defer cancel()
+	}
+	var response struct {
+		Data *string `json:"data"`
+	}
+	if err := device.post(ctx, "/apdu", map[string]string{"data": hex.EncodeToString(apdu)}, &response); err != nil {
+		return nil, err
+	}
+	if response.Data == nil {
+		return nil, fmt.Errorf("Wrong response")
+	}
+	return hex.DecodeString(*response.Data)
+}
+
+// Press Emulate press and release of the device button
+func (device *Speculos) Press(ctx context.Context, button SpeculosButton) error {
+	return device.post(ctx, "/button/"+string(button), map[string]string{"action": "press-and-release"}, nil)
+}
+
+// Events Subscribe to the screen events stream.
+// The channel is closed when the stream ends or the context is done.
+//
+// example
+// events, err := speculos.Events(ctx)
+//
+//	if err == nil {
+//		for event := range events {
+//			fmt.Printf("screen: %v\n", event.Text)
+//		}
+//	}
+func (device *Speculos) Events(ctx context.Context) (<-chan SpeculosEvent, error) {
+	req, err := http.NewRequest(http.MethodGet, device.URL+"/events?stream=true", nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := device.Client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("Speculos error %v", resp.StatusCode)
+	}
+
+	events := make(chan SpeculosEvent)
+	go func() {
+		defer close(events)
+		defer resp.Body.Close()
+		reader := bufio.NewReader(resp.Body)
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				return
+			}
+			if !strings.HasPrefix(line, "data: ") {
+				continue
+			}
+			var event SpeculosEvent
+			if err := json.Unmarshal([]byte(strings.TrimSpace(line[6:])), &event); err != nil {
+				continue
+			}
+			select {
+			case events <- event:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return events, nil
+}
+
+// SpeculosTCP Transport over the Speculos raw APDU TCP socket.
+// Request is a 4 bytes big endian length followed by APDU,
+// response is a 4 bytes big endian length followed by data and 2 bytes status word.
+type SpeculosTCP struct {
+	Info HidDeviceInfo
+	// Address of the APDU socket
+	Address string
+	// Timeout of the single APDU exchange, 0 - no timeout
+	Timeout time.Duration
+
+	conn  net.Conn
+	mutex sync.Mutex
+}
+
+// NewSpeculosTCP Create new Speculos APDU socket transport.
+//
+// param {string} address Address of the APDU socket, e.g. "127.0.0.1:9999".
+// return {*SpeculosTCP} Speculos transport, implements IHidDevice.
+//
+// example
+// device := ledger.NewLedger(ledger.NewSpeculosTCP(ledger.SpeculosDefaultAPDUAddress))
+//
+//	if err := device.Open(); err == nil {
+//		...
+//		device.Close()
+//	}
+func NewSpeculosTCP(address string) *SpeculosTCP {
+	return &SpeculosTCP{
+		Info:    HidDeviceInfo{Path: address},
+		Address: address,
+		Timeout: SpeculosDefaultTimeout,
+	}
+}
+
+// Open Connect to the APDU socket
+func (device *SpeculosTCP) Open() error {
+	device.mutex.Lock()
+	defer device.mutex.Unlock()
+	if device.conn != nil {
+		device.conn.Close()
+	}
+	conn, err := net.Dial("tcp", device.Address)
+	if err != nil {
+		device.conn = nil
+		return fmt.Errorf("cannot connect to %v: %v", device.Address, err)
+	}
+	device.conn = conn
+	return nil
+}
+
+// Close Close connection to the APDU socket
+func (device *SpeculosTCP) Close() {
+	device.mutex.Lock()
+	defer device.mutex.Unlock()
+	if device.conn != nil {
+		device.conn.Close()
+		device.conn = nil
+	}
+}
+
+// GetInfo Get Speculos device info
+func (device *SpeculosTCP) GetInfo() *HidDeviceInfo {
+	return &device.Info
+}
+
+// Exchange Exchange APDU packets over the socket
+func (device *SpeculosTCP) Exchange(apdu []byte) ([]byte, error) {
+	return device.ExchangeContext(context.Background(), apdu)
+}
+
+// ExchangeContext Exchange APDU packets over the socket.
+// When the context is done the connection is closed, the device must be opened again.
+func (device *SpeculosTCP) ExchangeContext(ctx context.Context, apdu []byte) ([]byte, error) {
+	device.mutex.Lock()
+	defer device.mutex.Unlock()
+	conn := device.conn
+	if conn == nil {
+		return nil, fmt.Errorf("Speculos socket is not opened")
+	}
+
+	if device.Timeout > 0 {
+		conn.SetDeadline(time.Now().Add(device.Timeout))
+	} else {
+		conn.SetDeadline(time.Time{})
+	}
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.SetDeadline(time.Unix(1, 0))
+		case <-done:
+		}
+	}()
+
+	response, err := speculosTCPExchange(conn, apdu)
+	if err != nil {
+		conn.Close()
+		device.conn = nil
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, err
+	}
+	return response, nil
+}
+
+// Write request and read response in the Speculos APDU socket format
+func speculosTCPExchange(conn io.ReadWriter, apdu []byte) ([]byte, error) {
+	request := make([]byte, 4+len(apdu))
+	binary.BigEndian.PutUint32(request, uint32(len(apdu)))
+	copy(request[4:], apdu)
+	if _, err := conn.Write(request); err != nil {
+		return nil, err
+	}
+
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return nil, err
+	}
+	size := binary.BigEndian.Uint32(header)
+	if size > ReadBuffMaxSize {
+		return nil, fmt.Errorf("Response is too long: %v bytes", size)
+	}
+	response := make([]byte, size+2)
+	if _, err := io.ReadFull(conn, response); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
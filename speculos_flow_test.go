@@ -0,0 +1,295 @@
+package ledger
+
+import (
+	"context"
+	"encoding/hex"
+	"testing"
+	"time"
+)
+
+// Speculos event description struct
+type speculosEvent struct {
+	text   string
+	skip   bool
+	action func() error
+}
+
+// Speculos test wrapper struct
+type speculos struct {
+	*Speculos
+	ctx    context.Context
+	step   int
+	events []speculosEvent
+	done   chan bool
+}
+
+// Create new Speculos object
+func newSpeculos(ctx context.Context, url string) *speculos {
+	return &speculos{
+		Speculos: NewSpeculos(url),
+		ctx:      ctx,
+		step:     -1,
+	}
+}
+
+// Processing Speculos events
+func (device *speculos) onEvent(text string) bool {
+	if device.step == -1 {
+		for i := 0; i < len(device.events); i++ {
+			if device.events[i].text == text {
+				if device.events[i].skip {
+					return true
+				}
+				device.step = i
+				break
+			}
+		}
+		if device.step == -1 {
+			panic("Unexpected event " + text)
+		}
+	}
+	event := &device.events[device.step]
+	if text != event.text {
+		panic("Unexpected event " + text)
+	}
+	if event.action != nil {
+		event.action()
+	}
+	device.step++
+	return device.step < len(device.events)
+}
+
+// Emulate of press left button on Ledger
+func (device *speculos) pressLeft() error {
+	return device.Press(device.ctx, SpeculosButtonLeft)
+}
+
+// Emulate of press both buttons on Ledger
+func (device *speculos) pressBoth() error {
+	return device.Press(device.ctx, SpeculosButtonBoth)
+}
+
+// Emulate of press right button on Ledger
+func (device *speculos) pressRight() error {
+	return device.Press(device.ctx, SpeculosButtonRight)
+}
+
+// Prepare the device for testing and start the Speculoos event pump.
+func (device *speculos) setupTest(ctx context.Context, events []speculosEvent) {
+	device.step = -1
+	device.events = events
+	device.done = make(chan bool, 1)
+
+	ctx, cancel := context.WithCancel(ctx)
+	stream, err := device.Events(ctx)
+	if err != nil {
+		cancel()
+		device.done <- false
+		return
+	}
+
+	go func() {
+		defer cancel()
+		for event := range stream {
+			if !device.onEvent(event.Text) {
+				device.done <- true
+				return
+			}
+		}
+		device.done <- false
+	}()
+}
+
+// Wait for a test to complete
+func (device *speculos) waitTestDone() bool {
+	return <-device.done
+}
+
+// Run tests on Speculos emilator
+func doSpeculosTests(t *testing.T, url string) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), 180*time.Second)
+	defer cancel()
+
+	ok := true
+	speculos := newSpeculos(ctx, url)
+	device := NewLedger(speculos)
+
+	path := StringToPath("44'/540'/0'/0/0'")
+
+	// run GetExtendedPublicKey test
+	speculos.setupTest(ctx, []speculosEvent{
+		{text: "Spacemesh", skip: true},
+		{text: "is ready", skip: true},
+		{text: "Export public key"},
+		{text: "m/44'/540'/0'/0/0", action: speculos.pressBoth},
+		{text: "Confirm export"},
+		{text: "public key?", action: speculos.pressRight},
+		{text: "Spacemesh"},
+		{text: "is ready"},
+	})
+
+	publicKey, err := device.GetExtendedPublicKey(path)
+	if err != nil {
+		ok = false
+		t.Logf("get public key ERROR: %v\n", err)
+	} else {
+		key := hex.EncodeToString(publicKey.PublicKey)
+		t.Logf("public key: %v\n", key)
+		if key != "a47a88814cecde42f2ad0d75123cf530fbe8e5940bbc44273014714df9a33e16" {
+			ok = false
+			t.Logf("WRONG public key\n")
+		} else {
+			t.Logf("Get public key: OK\n")
+		}
+	}
+
+	ok = ok && speculos.waitTestDone()
+	if !ok {
+		return false
+	}
+
+	// run GetAddress test
+	speculos.setupTest(ctx, []speculosEvent{
+		{text: "Spacemesh", skip: true},
+		{text: "is ready", skip: true},
+		{text: "Export address"},
+		{text: "Path: m/44'/540'/", action: speculos.pressBoth},
+		{text: "Confirm"},
+		{text: "export address?", action: speculos.pressRight},
+		{text: "Spacemesh"},
+		{text: "is ready"},
+	})
+
+	address, err := device.GetAddress(path)
+	if err != nil {
+		ok = false
+		t.Logf("get address ERROR: %v\n", err)
+	} else {
+		addressStr := hex.EncodeToString(address)
+		t.Logf("address: %v\n", addressStr)
+		if addressStr != "a47a88814cecde42f2ad0d75123cf530fbe8e594" {
+			ok = false
+			t.Logf("WRONG address\n")
+		} else {
+			t.Logf("Get address: OK\n")
+		}
+	}
+
+	ok = ok && speculos.waitTestDone()
+	if !ok {
+		return false
+	}
+
+	// run ShowAddress test
+	speculos.setupTest(ctx, []speculosEvent{
+		{text: "Spacemesh", skip: true},
+		{text: "is ready", skip: true},
+		{text: "Verify address"},
+		{text: "Make sure it agre", action: speculos.pressBoth},
+		{text: "Address path"},
+		{text: "m/44'/540'/0'/0/0", action: speculos.pressBoth},
+		{text: "Address"},
+		{text: "a47a88814cecde42f", action: speculos.pressBoth},
+		{text: "Spacemesh"},
+		{text: "is ready"},
+	})
+
+	err = device.ShowAddress(path)
+	if err != nil {
+		ok = false
+		t.Logf("Show address ERROR: %v\n", err)
+	} else {
+		t.Logf("Show address: OK\n")
+	}
+
+	ok = ok && speculos.waitTestDone()
+	if !ok {
+		return false
+	}
+
+	// run Sign coin transaction test
+	speculos.setupTest(ctx, []speculosEvent{
+		{text: "Spacemesh", skip: true},
+		{text: "is ready", skip: true},
+		{text: "Tx type:"},
+		{text: "COIN ED", action: speculos.pressBoth},
+		{text: "Send SMH"},
+		{text: "1.0", action: speculos.pressBoth},
+		{text: "To address"},
+		{text: "a47a88814cecde42f", action: speculos.pressBoth},
+		{text: "Max Tx Fee"},
+		{text: "0.001", action: speculos.pressBoth},
+		{text: "Confirm"},
+		{text: "transaction?", action: speculos.pressRight},
+		{text: "Signer"},
+		{text: "a47a88814cecde42f", action: speculos.pressBoth},
+		{text: "Sign using"},
+		{text: "this signer?", action: speculos.pressRight},
+		{text: "Spacemesh"},
+		{text: "is ready"},
+	})
+
+	ok = testTx(t, device, "coin.tx.json", "coin", publicKey.PublicKey, nil)
+	ok = ok && speculos.waitTestDone()
+	if !ok {
+		return false
+	}
+
+	// run Sign app transaction test
+	speculos.setupTest(ctx, []speculosEvent{
+		{text: "Spacemesh", skip: true},
+		{text: "is ready", skip: true},
+		{text: "Tx type:"},
+		{text: "EXEC APP ED", action: speculos.pressBoth},
+		{text: "Send SMH"},
+		{text: "1.0", action: speculos.pressBoth},
+		{text: "To address"},
+		{text: "a47a88814cecde42f", action: speculos.pressBoth},
+		{text: "Max Tx Fee"},
+		{text: "0.001", action: speculos.pressBoth},
+		{text: "Confirm"},
+		{text: "transaction?", action: speculos.pressRight},
+		{text: "Signer"},
+		{text: "a47a88814cecde42f", action: speculos.pressBoth},
+		{text: "Sign using"},
+		{text: "this signer?", action: speculos.pressRight},
+		{text: "Spacemesh"},
+		{text: "is ready"},
+	})
+
+	ok = testTx(t, device, "app.tx.json", "app", publicKey.PublicKey, nil)
+	ok = ok && speculos.waitTestDone()
+	if !ok {
+		return false
+	}
+
+	// run Sign spawn transaction test
+	speculos.setupTest(ctx, []speculosEvent{
+		{text: "Spacemesh", skip: true},
+		{text: "is ready", skip: true},
+		{text: "Tx type:"},
+		{text: "SPAWN APP ED", action: speculos.pressBoth},
+		{text: "Send SMH"},
+		{text: "1.0", action: speculos.pressBoth},
+		{text: "To address"},
+		{text: "a47a88814cecde42f", action: speculos.pressBoth},
+		{text: "Max Tx Fee"},
+		{text: "0.001", action: speculos.pressBoth},
+		{text: "Confirm"},
+		{text: "transaction?", action: speculos.pressRight},
+		{text: "Signer"},
+		{text: "a47a88814cecde42f", action: speculos.pressBoth},
+		{text: "Sign using"},
+		{text: "this signer?", action: speculos.pressRight},
+		{text: "Spacemesh"},
+		{text: "is ready"},
+	})
+
+	ok = testTx(t, device, "spawn.tx.json", "spawn", publicKey.PublicKey, nil)
+	ok = ok && speculos.waitTestDone()
+	if !ok {
+		return false
+	}
+
+	return ok
+}
@@ -0,0 +1,407 @@
+package ledger
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+const (
+	// Number of characters visible on a screen line of Nano S
+	cSpeculosLineWidth = 17
+	// Vertical position of the first screen line
+	cSpeculosHeaderY = 3
+	// Vertical position of the second screen line
+	cSpeculosTextY = 17
+)
+
+// Idle screen of the Spacemesh application
+var speculosIdleScreen = EmulatorScreen{Header: "Spacemesh", Text: "is ready"}
+
+// Subscriber of the events stream
+type speculosSubscriber struct {
+	mutex  sync.Mutex
+	queue  []SpeculosEvent
+	notify chan struct{}
+}
+
+// Add events to the subscriber queue without blocking
+func (subscriber *speculosSubscriber) push(events []SpeculosEvent) {
+	subscriber.mutex.Lock()
+	subscriber.queue = append(subscriber.queue, events...)
+	subscriber.mutex.Unlock()
+	select {
+	case subscriber.notify <- struct{}{}:
+	default:
+	}
+}
+
+// Take all queued events
+func (subscriber *speculosSubscriber) pop() []SpeculosEvent {
+	subscriber.mutex.Lock()
+	defer subscriber.mutex.Unlock()
+	events := subscriber.queue
+	subscriber.queue = nil
+	return events
+}
+
+// SpeculosServer Local HTTP server implementing the subset of the Speculos REST API
+// (POST /apdu, POST /button/{left,right,both}, GET /events) on top of the Emulator.
+// The emulated user walks through the application screens with the button requests.
+type SpeculosServer struct {
+	emulator *Emulator
+
+	mutex       sync.Mutex
+	screens     []EmulatorScreen
+	index       int
+	result      chan bool
+	events      []SpeculosEvent
+	subscribers map[*speculosSubscriber]struct{}
+
+	listener     net.Listener
+	server       *http.Server
+	apduListener net.Listener
+}
+
+// NewSpeculosServer Create new Speculos compatible server.
+// The server becomes the user of the emulator.
+func NewSpeculosServer(emulator *Emulator) *SpeculosServer {
+	server := &SpeculosServer{
+		emulator:    emulator,
+		subscribers: make(map[*speculosSubscriber]struct{}),
+	}
+	emulator.User = server
+	return server
+}
+
+// Start Open the emulator and start serving HTTP requests.
+//
+// param {string} address TCP address to listen on, e.g. "127.0.0.1:5001" or "127.0.0.1:0" for random port.
+// return {error} Error value.
+//
+// example
+// server := ledger.NewSpeculosServer(ledger.NewEmulator(ledger.MnemonicToSeed("secret", "")))
+//
+//	if err := server.Start("127.0.0.1:5001"); err == nil {
+//		...
+//		server.Close()
+//	}
+func (server *SpeculosServer) Start(address string) error {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return err
+	}
+	if err := server.emulator.Open(); err != nil {
+		listener.Close()
+		return err
+	}
+	server.listener = listener
+	server.server = &http.Server{Handler: server}
+	go server.server.Serve(listener)
+	return nil
+}
+
+// StartAPDU Start serving the raw APDU TCP socket protocol, see SpeculosTCP.
+//
+// param {string} address TCP address to listen on, e.g. "127.0.0.1:9999" or "127.0.0.1:0" for random port.
+// return {error} Error value.
+func (server *SpeculosServer) StartAPDU(address string) error {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return err
+	}
+	if err := server.emulator.Open(); err != nil {
+		listener.Close()
+		return err
+	}
+	server.apduListener = listener
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go server.serveAPDU(conn)
+		}
+	}()
+	return nil
+}
+
+// Serve APDU socket connection
+func (server *SpeculosServer) serveAPDU(conn net.Conn) {
+	defer conn.Close()
+	header := make([]byte, 4)
+	for {
+		if _, err := io.ReadFull(conn, header); err != nil {
+			return
+		}
+		size := binary.BigEndian.Uint32(header)
+		if size > ReadBuffMaxSize {
+			return
+		}
+		apdu := make([]byte, size)
+		if _, err := io.ReadFull(conn, apdu); err != nil {
+			return
+		}
+		response, err := server.emulator.Exchange(apdu)
+		if err != nil || len(response) < 2 {
+			return
+		}
+		packet := make([]byte, 4+len(response))
+		binary.BigEndian.PutUint32(packet, uint32(len(response)-2))
+		copy(packet[4:], response)
+		if _, err := conn.Write(packet); err != nil {
+			return
+		}
+	}
+}
+
+// APDUAddress Address of the started APDU socket
+func (server *SpeculosServer) APDUAddress() string {
+	if server.apduListener == nil {
+		return ""
+	}
+	return server.apduListener.Addr().String()
+}
+
+// URL Base URL of the started server
+func (server *SpeculosServer) URL() string {
+	if server.listener == nil {
+		return ""
+	}
+	return "http://" + server.listener.Addr().String()
+}
+
+// Close Stop the server, pending action is rejected.
+func (server *SpeculosServer) Close() error {
+	server.mutex.Lock()
+	if server.result != nil {
+		server.finish(false)
+	}
+	server.mutex.Unlock()
+
+	var err error
+	if server.server != nil {
+		err = server.server.Close()
+		server.server = nil
+		server.listener = nil
+	}
+	if server.apduListener != nil {
+		server.apduListener.Close()
+		server.apduListener = nil
+	}
+	server.emulator.Close()
+	return err
+}
+
+// Review Show action screens and wait for the user to walk through them with the buttons,
+// an action without screens is approved
+func (server *SpeculosServer) Review(screens []EmulatorScreen) bool {
+	// Nothing to confirm
+	if len(screens) == 0 {
+		return true
+	}
+	result := make(chan bool, 1)
+	server.mutex.Lock()
+	server.screens = screens
+	server.index = 0
+	server.result = result
+	server.show(screens[0])
+	server.mutex.Unlock()
+	return <-result
+}
+
+// Current screen, must be called with the mutex locked
+func (server *SpeculosServer) current() EmulatorScreen {
+	if server.result == nil {
+		return speculosIdleScreen
+	}
+	return server.screens[server.index]
+}
+
+// Convert screen to text events
+func speculosScreenEvents(screen EmulatorScreen) []SpeculosEvent {
+	return []SpeculosEvent{
+		{Text: speculosVisibleText(screen.Header), Y: cSpeculosHeaderY},
+		{Text: speculosVisibleText(screen.Text), Y: cSpeculosTextY},
+	}
+}
+
+// Visible part of the scrolling screen line
+func speculosVisibleText(text string) string {
+	if len(text) > cSpeculosLineWidth {
+		return text[:cSpeculosLineWidth]
+	}
+	return text
+}
+
+// Display screen and broadcast its events, must be called with the mutex locked
+func (server *SpeculosServer) show(screen EmulatorScreen) {
+	events := speculosScreenEvents(screen)
+	server.events = append(server.events, events...)
+	for subscriber := range server.subscribers {
+		subscriber.push(events)
+	}
+}
+
+// Complete the action and return to the idle screen, must be called with the mutex locked
+func (server *SpeculosServer) finish(approved bool) {
+	server.result <- approved
+	server.result = nil
+	server.screens = nil
+	server.index = 0
+	server.show(speculosIdleScreen)
+}
+
+// Process button press, must be called with the mutex locked
+func (server *SpeculosServer) press(button SpeculosButton) {
+	if server.result == nil {
+		return
+	}
+	screen := server.current()
+	switch {
+	case screen.Confirm && button == SpeculosButtonLeft:
+		server.finish(false)
+		return
+	case screen.Confirm && button == SpeculosButtonRight, !screen.Confirm && button != SpeculosButtonLeft:
+		if server.index+1 == len(server.screens) {
+			server.finish(true)
+			return
+		}
+		server.index++
+	case !screen.Confirm && server.index > 0:
+		server.index--
+	default:
+		return
+	}
+	server.show(server.current())
+}
+
+// Write JSON response
+func speculosWriteJSON(w http.ResponseWriter, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(value)
+}
+
+// ServeHTTP Handle Speculos API request
+func (server *SpeculosServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.URL.Path == "/apdu" && r.Method == http.MethodPost:
+		server.handleApdu(w, r)
+	case strings.HasPrefix(r.URL.Path, "/button/") && r.Method == http.MethodPost:
+		server.handleButton(w, r)
+	case r.URL.Path == "/events" && r.Method == http.MethodGet:
+		if r.URL.Query().Get("stream") == "true" {
+			server.handleEventsStream(w, r)
+		} else {
+			server.mutex.Lock()
+			events := append([]SpeculosEvent{}, server.events...)
+			server.mutex.Unlock()
+			speculosWriteJSON(w, map[string]interface{}{"events": events})
+		}
+	case r.URL.Path == "/events" && r.Method == http.MethodDelete:
+		server.mutex.Lock()
+		server.events = nil
+		server.mutex.Unlock()
+		speculosWriteJSON(w, map[string]interface{}{})
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// Handle POST /apdu request
+func (server *SpeculosServer) handleApdu(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Data string `json:"data"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, fmt.Sprintf("Wrong request: %v", err), http.StatusBadRequest)
+		return
+	}
+	apdu, err := hex.DecodeString(request.Data)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Wrong APDU: %v", err), http.StatusBadRequest)
+		return
+	}
+	response, err := server.emulator.Exchange(apdu)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	speculosWriteJSON(w, map[string]interface{}{"data": hex.EncodeToString(response)})
+}
+
+// Handle POST /button/{left,right,both} request
+func (server *SpeculosServer) handleButton(w http.ResponseWriter, r *http.Request) {
+	button := SpeculosButton(strings.TrimPrefix(r.URL.Path, "/button/"))
+	if button != SpeculosButtonLeft && button != SpeculosButtonRight && button != SpeculosButtonBoth {
+		http.NotFound(w, r)
+		return
+	}
+	var request struct {
+		Action string `json:"action"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, fmt.Sprintf("Wrong request: %v", err), http.StatusBadRequest)
+		return
+	}
+	switch request.Action {
+	case "press-and-release", "release":
+		server.mutex.Lock()
+		server.press(button)
+		server.mutex.Unlock()
+	case "press":
+	default:
+		http.Error(w, fmt.Sprintf("Wrong action: %v", request.Action), http.StatusBadRequest)
+		return
+	}
+	speculosWriteJSON(w, map[string]interface{}{})
+}
+
+// Handle GET /events?stream=true request, the current screen is sent first
+func (server *SpeculosServer) handleEventsStream(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+	subscriber := &speculosSubscriber{notify: make(chan struct{}, 1)}
+	server.mutex.Lock()
+	subscriber.push(speculosScreenEvents(server.current()))
+	server.subscribers[subscriber] = struct{}{}
+	server.mutex.Unlock()
+	defer func() {
+		server.mutex.Lock()
+		delete(server.subscribers, subscriber)
+		server.mutex.Unlock()
+	}()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-subscriber.notify:
+		}
+		for _, event := range subscriber.pop() {
+			data, err := json.Marshal(event)
+			if err != nil {
+				return
+			}
+			if _, err := fmt.Fprintf(w, "data: %s\n\n", data); err != nil {
+				return
+			}
+		}
+		flusher.Flush()
+	}
+}
@@ -0,0 +1,82 @@
+package ledger
+
+import (
+	"context"
+	"encoding/hex"
+	"testing"
+	"time"
+)
+
+// Run Speculos screen flow tests against the emulator backed server
+func TestSpeculosServer(t *testing.T) {
+	server := NewSpeculosServer(NewEmulator(MnemonicToSeed("secret", "")))
+	if err := server.Start("127.0.0.1:0"); err != nil {
+		t.Fatalf("start server ERROR: %v\n", err)
+	}
+	defer server.Close()
+
+	if !doSpeculosTests(t, server.URL()) {
+		t.FailNow()
+	}
+}
+
+// Exchange APDU over the raw socket protocol
+func TestSpeculosTCP(t *testing.T) {
+	server := NewSpeculosServer(NewEmulator(MnemonicToSeed("secret", "")))
+	if err := server.StartAPDU("127.0.0.1:0"); err != nil {
+		t.Fatalf("start APDU server ERROR: %v\n", err)
+	}
+	defer server.Close()
+
+	// Confirm actions without screen flow
+	server.emulator.User = nil
+	device := NewLedger(NewSpeculosTCP(server.APDUAddress()))
+	if _, err := device.GetVersion(); err == nil {
+		t.Fatalf("exchange with not opened socket should fail\n")
+	}
+	if err := device.Open(); err != nil {
+		t.Fatalf("open socket ERROR: %v\n", err)
+	}
+	defer device.Close()
+
+	publicKey, err := device.GetExtendedPublicKey(StringToPath("44'/540'/0'/0/0'"))
+	if err != nil {
+		t.Fatalf("get public key ERROR: %v\n", err)
+	}
+	if key := hex.EncodeToString(publicKey.PublicKey); key != testPublicKey {
+		t.Fatalf("WRONG public key: %v\n", key)
+	}
+	if !testTx(t, device, "app.tx.json", "app", publicKey.PublicKey, nil) {
+		t.FailNow()
+	}
+}
+
+// Abort pending user confirmation with context
+func TestSpeculosCancel(t *testing.T) {
+	server := NewSpeculosServer(NewEmulator(MnemonicToSeed("secret", "")))
+	if err := server.Start("127.0.0.1:0"); err != nil {
+		t.Fatalf("start server ERROR: %v\n", err)
+	}
+	defer server.Close()
+
+	device := NewSpeculos(server.URL())
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	apdu := append([]byte{cCLA, cInsGetExtPublicKey, 0, 0, 21}, pathToBytes(StringToPath("44'/540'/0'/0/0'"))...)
+	if _, err := device.ExchangeContext(ctx, apdu); err == nil {
+		t.Fatalf("exchange without confirmation should fail\n")
+	}
+}
+
+// Action without screens does not block the server
+func TestSpeculosServerNoScreens(t *testing.T) {
+	server := NewSpeculosServer(NewEmulator(MnemonicToSeed("secret", "")))
+	if !server.Review(nil) {
+		t.Fatalf("WRONG review result of empty screen list\n")
+	}
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	if screen := server.current(); screen != speculosIdleScreen {
+		t.Fatalf("WRONG current screen: %+v\n", screen)
+	}
+}
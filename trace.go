@@ -0,0 +1,382 @@
+package ledger
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// TraceDirection Direction of traced APDU
+type TraceDirection int
+
+const (
+	// TraceSend Command sent to the device
+	TraceSend TraceDirection = iota
+	// TraceReceive Response received from the device or exchange failure
+	TraceReceive
+)
+
+// String Direction name, "send" or "receive"
+func (direction TraceDirection) String() string {
+	switch direction {
+	case TraceSend:
+		return "send"
+	case TraceReceive:
+		return "receive"
+	default:
+		return fmt.Sprintf("TraceDirection(%d)", int(direction))
+	}
+}
+
+// TraceEvent APDU command sent to the device or its response
+type TraceEvent struct {
+	// Number of the exchange, the command and its response have the same number
+	Seq int
+	// Send or receive
+	Direction TraceDirection
+	// Time of the event
+	Time time.Time
+	// Time elapsed since the command was sent, receive only
+	Duration time.Duration
+	// Device path
+	Device string
+	// Command APDU or response with status word, nil if the exchange failed
+	Data []byte
+	// Status word of the response, receive only
+	SW uint16
+	// Exchange error, receive only
+	Err error
+}
+
+// Status Description of the response status word, empty for sent commands and failed exchanges
+func (event *TraceEvent) Status() string {
+	if event.Direction != TraceReceive || event.Data == nil {
+		return ""
+	}
+	if event.SW == StatusOK {
+		return "OK"
+	}
+	return (&StatusError{SW: event.SW}).Error()
+}
+
+// JSON representation of TraceEvent, byte strings are hex encoded
+type traceEventJSON struct {
+	Seq       int       `json:"seq"`
+	Direction string    `json:"direction"`
+	Time      time.Time `json:"time"`
+	Duration  string    `json:"duration,omitempty"`
+	Device    string    `json:"device,omitempty"`
+	Data      *string   `json:"data,omitempty"`
+	SW        string    `json:"sw,omitempty"`
+	Status    string    `json:"status,omitempty"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// MarshalJSON Encode event as JSON object with hex encoded data and status word
+func (event *TraceEvent) MarshalJSON() ([]byte, error) {
+	value := traceEventJSON{
+		Seq:       event.Seq,
+		Direction: event.Direction.String(),
+		Time:      event.Time,
+		Device:    event.Device,
+		Status:    event.Status(),
+	}
+	if event.Data != nil {
+		data := hex.EncodeToString(event.Data)
+		value.Data = &data
+	}
+	if event.Direction == TraceReceive {
+		value.Duration = event.Duration.String()
+		if event.Data != nil {
+			value.SW = fmt.Sprintf("%04x", event.SW)
+		}
+	}
+	if event.Err != nil {
+		value.Error = event.Err.Error()
+	}
+	return json.Marshal(&value)
+}
+
+// UnmarshalJSON Decode event encoded by MarshalJSON, the error is restored as plain message
+func (event *TraceEvent) UnmarshalJSON(data []byte) error {
+	var value traceEventJSON
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	decoded := TraceEvent{Seq: value.Seq, Time: value.Time, Device: value.Device}
+	switch value.Direction {
+	case TraceSend.String():
+		decoded.Direction = TraceSend
+	case TraceReceive.String():
+		decoded.Direction = TraceReceive
+	default:
+		return fmt.Errorf("Unknown trace direction: %q", value.Direction)
+	}
+	if value.Duration != "" {
+		duration, err := time.ParseDuration(value.Duration)
+		if err != nil {
+			return fmt.Errorf("Invalid trace duration: %w", err)
+		}
+		decoded.Duration = duration
+	}
+	if value.Data != nil {
+		decodedData, err := hex.DecodeString(*value.Data)
+		if err != nil {
+			return fmt.Errorf("Invalid trace data: %w", err)
+		}
+		decoded.Data = decodedData
+		if decoded.Direction == TraceReceive {
+			_, sw := stripRetcodeFromResponse(decodedData)
+			decoded.SW = uint16(sw)
+		}
+	}
+	if value.Error != "" {
+		decoded.Err = errors.New(value.Error)
+	}
+	*event = decoded
+	return nil
+}
+
+// TraceSink Receiver of trace events, called synchronously from the exchange
+type TraceSink interface {
+	Trace(event *TraceEvent)
+}
+
+// TraceSinkFunc Adapter to use ordinary function as TraceSink
+type TraceSinkFunc func(event *TraceEvent)
+
+// Trace calls f(event)
+func (f TraceSinkFunc) Trace(event *TraceEvent) {
+	f(event)
+}
+
+// TraceLogger Structured logger taking a message and alternating keys and values, *slog.Logger satisfies it
+type TraceLogger interface {
+	Debug(msg string, args ...interface{})
+}
+
+// NewLoggerTraceSink Log trace events at debug level
+func NewLoggerTraceSink(logger TraceLogger) TraceSink {
+	return TraceSinkFunc(func(event *TraceEvent) {
+		args := []interface{}{"seq", event.Seq}
+		if event.Device != "" {
+			args = append(args, "device", event.Device)
+		}
+		if event.Data != nil {
+			args = append(args, "data", hex.EncodeToString(event.Data))
+		}
+		if event.Direction == TraceSend {
+			if len(event.Data) >= 2 {
+				args = append(args, "cla", fmt.Sprintf("%02x", event.Data[0]), "ins", fmt.Sprintf("%02x", event.Data[1]))
+			}
+			logger.Debug("APDU send", args...)
+			return
+		}
+		args = append(args, "duration", event.Duration)
+		if event.Data != nil {
+			args = append(args, "sw", fmt.Sprintf("%04x", event.SW), "status", event.Status())
+		}
+		if event.Err != nil {
+			args = append(args, "error", event.Err)
+		}
+		logger.Debug("APDU receive", args...)
+	})
+}
+
+// JSONTraceSink Write trace events as JSON lines
+type JSONTraceSink struct {
+	encoder *json.Encoder
+	err     error
+	mutex   sync.Mutex
+}
+
+// NewJSONTraceSink Create sink writing one JSON object per line
+func NewJSONTraceSink(writer io.Writer) *JSONTraceSink {
+	return &JSONTraceSink{encoder: json.NewEncoder(writer)}
+}
+
+// Trace Write the event, the first write error stops writing
+func (sink *JSONTraceSink) Trace(event *TraceEvent) {
+	sink.mutex.Lock()
+	defer sink.mutex.Unlock()
+	if sink.err == nil {
+		sink.err = sink.encoder.Encode(event)
+	}
+}
+
+// Err The first write error
+func (sink *JSONTraceSink) Err() error {
+	sink.mutex.Lock()
+	defer sink.mutex.Unlock()
+	return sink.err
+}
+
+// TraceRing Keep the most recent trace events in memory
+type TraceRing struct {
+	events []TraceEvent
+	next   int
+	full   bool
+	mutex  sync.Mutex
+}
+
+// NewTraceRing Create ring buffer of the given number of events
+func NewTraceRing(size int) *TraceRing {
+	if size < 1 {
+		size = 1
+	}
+	return &TraceRing{events: make([]TraceEvent, size)}
+}
+
+// Trace Store the event, the oldest event is dropped when the buffer is full
+func (ring *TraceRing) Trace(event *TraceEvent) {
+	ring.mutex.Lock()
+	defer ring.mutex.Unlock()
+	ring.events[ring.next] = *event
+	ring.next = (ring.next + 1) % len(ring.events)
+	if ring.next == 0 {
+		ring.full = true
+	}
+}
+
+// Events Stored events, the oldest first
+func (ring *TraceRing) Events() []TraceEvent {
+	ring.mutex.Lock()
+	defer ring.mutex.Unlock()
+	if !ring.full {
+		return append([]TraceEvent{}, ring.events[:ring.next]...)
+	}
+	return append(append([]TraceEvent{}, ring.events[ring.next:]...), ring.events[:ring.next]...)
+}
+
+// TracingDevice HID device wrapper reporting every command and response to trace sinks
+type TracingDevice struct {
+	device    IHidDevice
+	sinks     []TraceSink
+	seq       int
+	mutex     sync.Mutex
+	exchanger hidExchanger
+}
+
+// NewTracingDevice Wrap the device to trace its exchanges.
+//
+// param {IHidDevice} device Traced device.
+// param {...TraceSink} sinks Receivers of the events.
+// return {*TracingDevice} Wrapped device.
+//
+// example
+// ring := ledger.NewTraceRing(100)
+// device := ledger.NewLedger(ledger.NewTracingDevice(ledger.NewEmulator(seed), ring))
+//
+//	if _, err := device.SignTx(path, tx); err != nil {
+//		for _, event := range ring.Events() {
+//			fmt.Printf("%v %v %x %v\n", event.Seq, event.Direction, event.Data, event.Err)
+//		}
+//	}
+func NewTracingDevice(device IHidDevice, sinks ...TraceSink) *TracingDevice {
+	return &TracingDevice{device: device, sinks: sinks}
+}
+
+// Device The traced device
+func (tracing *TracingDevice) Device() IHidDevice {
+	return tracing.device
+}
+
+// Open Open the traced device
+func (tracing *TracingDevice) Open() error {
+	return tracing.device.Open()
+}
+
+// Close Close the traced device
+func (tracing *TracingDevice) Close() {
+	tracing.device.Close()
+}
+
+// GetInfo Get info of the traced device
+func (tracing *TracingDevice) GetInfo() *HidDeviceInfo {
+	return tracing.device.GetInfo()
+}
+
+// Exchange Exchange APDU with the traced device
+func (tracing *TracingDevice) Exchange(apdu []byte) ([]byte, error) {
+	return tracing.ExchangeContext(context.Background(), apdu)
+}
+
+// ExchangeContext Exchange APDU with the traced device, the command is traced before it is sent
+// and the response or error after the exchange completes.
+func (tracing *TracingDevice) ExchangeContext(ctx context.Context, apdu []byte) ([]byte, error) {
+	tracing.mutex.Lock()
+	tracing.seq++
+	seq := tracing.seq
+	tracing.mutex.Unlock()
+
+	var device string
+	if info := tracing.device.GetInfo(); info != nil {
+		device = info.Path
+	}
+	start := time.Now()
+	tracing.trace(&TraceEvent{
+		Seq:       seq,
+		Direction: TraceSend,
+		Time:      start,
+		Device:    device,
+		Data:      append([]byte{}, apdu...),
+	})
+
+	response, err := tracing.exchanger.exchange(ctx, tracing.device, apdu)
+
+	event := &TraceEvent{
+		Seq:       seq,
+		Direction: TraceReceive,
+		Time:      time.Now(),
+		Device:    device,
+		Err:       err,
+	}
+	event.Duration = event.Time.Sub(start)
+	if err == nil {
+		event.Data = append([]byte{}, response...)
+		_, sw := stripRetcodeFromResponse(response)
+		event.SW = uint16(sw)
+	}
+	tracing.trace(event)
+	return response, err
+}
+
+func (tracing *TracingDevice) trace(event *TraceEvent) {
+	for _, sink := range tracing.sinks {
+		sink.Trace(event)
+	}
+}
+
+// SetTraceSinks Trace exchanges of the device, no sinks stop tracing.
+// Call before using the device, the device must not be used concurrently.
+//
+// param {...TraceSink} sinks Receivers of the events.
+//
+// example
+// file, _ := os.Create("apdu.jsonl")
+// device.SetTraceSinks(ledger.NewJSONTraceSink(file), ledger.NewLoggerTraceSink(slog.Default()))
+func (device *Ledger) SetTraceSinks(sinks ...TraceSink) {
+	if tracing, ok := device.hid.(*TracingDevice); ok {
+		device.hid = tracing.device
+		device.exchanger.inherit(&tracing.exchanger)
+	}
+	if len(sinks) > 0 {
+		tracing := NewTracingDevice(device.hid, sinks...)
+		tracing.exchanger.inherit(&device.exchanger)
+		device.hid = tracing
+	}
+}
+
+// Replace the HID device keeping the tracing wrapper
+func (device *Ledger) setHid(hid IHidDevice) {
+	if tracing, ok := device.hid.(*TracingDevice); ok {
+		tracing.device = hid
+		return
+	}
+	device.hid = hid
+}
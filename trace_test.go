@@ -0,0 +1,163 @@
+package ledger
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// Logger collecting formatted messages
+type testTraceLogger struct {
+	messages []string
+}
+
+func (logger *testTraceLogger) Debug(msg string, args ...interface{}) {
+	logger.messages = append(logger.messages, fmt.Sprint(append([]interface{}{msg}, args...)...))
+}
+
+// Valid transaction signed in several chunks
+func newTestExecAppTx(t *testing.T) []byte {
+	publicKey, _ := hex.DecodeString(testPublicKey)
+	tx, err := (&ExecAppTx{
+		TxHeader: TxHeader{
+			NetworkID: make([]byte, NetworkIDSize),
+			Recipient: make([]byte, AddressSize),
+			PublicKey: publicKey,
+		},
+		Data: make([]byte, 500),
+	}).Encode()
+	if err != nil {
+		t.Fatalf("encode tx ERROR: %v\n", err)
+	}
+	return tx
+}
+
+func TestTracingDevice(t *testing.T) {
+	ring := NewTraceRing(100)
+	var buffer bytes.Buffer
+	jsonSink := NewJSONTraceSink(&buffer)
+	logger := &testTraceLogger{}
+	device := NewLedger(NewTracingDevice(newTestEmulator(t), ring, jsonSink, NewLoggerTraceSink(logger)))
+	path := StringToPath("44'/540'/0'/0/0'")
+
+	if _, err := device.SignTx(path, newTestExecAppTx(t)); err != nil {
+		t.Fatalf("sign tx ERROR: %v\n", err)
+	}
+	if err := device.OpenApp("Bitcoin"); !errors.Is(err, ErrAppNotOpen) {
+		t.Fatalf("WRONG open app error: %v\n", err)
+	}
+
+	events := ring.Events()
+	// Signing path and 617 bytes of tx in 3 chunks, then opening other app, which fails in the Spacemesh app
+	if len(events) != 8 {
+		t.Fatalf("WRONG event count: %v\n", len(events))
+	}
+	for i, event := range events {
+		seq, direction := i/2+1, TraceDirection(i%2)
+		if event.Seq != seq || event.Direction != direction || event.Err != nil || event.Data == nil {
+			t.Fatalf("WRONG event %v: %+v\n", i, event)
+		}
+		if event.Direction == TraceSend && (event.Data[0] != 0x30 && event.Data[0] != cCLADashboard) {
+			t.Fatalf("WRONG command %v: %x\n", i, event.Data)
+		}
+		if event.Direction == TraceReceive && event.Duration <= 0 {
+			t.Fatalf("WRONG duration %v: %v\n", i, event.Duration)
+		}
+	}
+	if last := events[7]; last.SW != cSwAppNotLaunched || last.Status() != (&StatusError{SW: cSwAppNotLaunched}).Error() {
+		t.Fatalf("WRONG status: %x %v\n", last.SW, last.Status())
+	}
+	if events[5].Status() != "OK" || events[4].Status() != "" {
+		t.Fatalf("WRONG status: %v %v\n", events[5].Status(), events[4].Status())
+	}
+
+	// JSON lines decode to the same events
+	if err := jsonSink.Err(); err != nil {
+		t.Fatalf("JSON sink ERROR: %v\n", err)
+	}
+	scanner := bufio.NewScanner(&buffer)
+	for i := 0; scanner.Scan(); i++ {
+		var event TraceEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			t.Fatalf("decode event ERROR: %v\n", err)
+		}
+		if event.Seq != events[i].Seq || event.Direction != events[i].Direction || !bytes.Equal(event.Data, events[i].Data) ||
+			event.SW != events[i].SW || event.Duration != events[i].Duration || !event.Time.Equal(events[i].Time) {
+			t.Fatalf("WRONG decoded event %v: %+v\n", i, event)
+		}
+	}
+
+	if len(logger.messages) != 8 {
+		t.Fatalf("WRONG log: %v\n", logger.messages)
+	}
+	if expected := fmt.Sprint("APDU receive", "seq", 4, "device", "emulator", "data", "6e00", "duration", events[7].Duration,
+		"sw", "6e00", "status", events[7].Status()); logger.messages[7] != expected {
+		t.Fatalf("WRONG log message: %v\n", logger.messages[7])
+	}
+}
+
+func TestTracingDeviceHang(t *testing.T) {
+	emulator := newTestEmulator(t)
+	confirm := make(chan bool)
+	emulator.User = EmulatorUserFunc(func(screens []EmulatorScreen) bool {
+		return <-confirm
+	})
+	ring := NewTraceRing(2)
+	device := NewLedger(emulator)
+	device.SetTraceSinks(ring)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := device.SignTxContext(ctx, StringToPath("44'/540'/0'/0/0'"), newTestExecAppTx(t))
+	var canceled *CanceledError
+	if !errors.As(err, &canceled) {
+		t.Fatalf("WRONG cancel error: %v\n", err)
+	}
+	confirm <- false
+
+	// The last chunk was sent and the device did not respond
+	events := ring.Events()
+	if len(events) != 2 || events[0].Direction != TraceSend || events[0].Seq != 3 || events[0].Data[2] != cP1IsLast {
+		t.Fatalf("WRONG events: %+v\n", events)
+	}
+	if events[1].Direction != TraceReceive || !errors.As(events[1].Err, &canceled) || events[1].Data != nil {
+		t.Fatalf("WRONG events: %+v\n", events)
+	}
+
+	device.SetTraceSinks()
+	if _, ok := device.hid.(*Emulator); !ok {
+		t.Fatalf("WRONG device after tracing stopped: %T\n", device.hid)
+	}
+	if _, err := device.GetVersion(); err != nil {
+		t.Fatalf("get version ERROR: %v\n", err)
+	}
+	if len(ring.Events()) != 2 || ring.Events()[1].Seq != 3 {
+		t.Fatalf("WRONG events after tracing stopped: %+v\n", ring.Events())
+	}
+}
+
+func TestTraceEventJSON(t *testing.T) {
+	event := TraceEvent{Seq: 1, Direction: TraceReceive, Time: time.Unix(1, 0).UTC(), Duration: time.Second, Err: errors.New("timeout")}
+	data, err := json.Marshal(&event)
+	if err != nil {
+		t.Fatalf("encode ERROR: %v\n", err)
+	}
+	if expected := `{"seq":1,"direction":"receive","time":"1970-01-01T00:00:01Z","duration":"1s","error":"timeout"}`; string(data) != expected {
+		t.Fatalf("WRONG JSON: %s\n", data)
+	}
+	var decoded TraceEvent
+	if err := json.Unmarshal(data, &decoded); err != nil || decoded.Err.Error() != "timeout" || decoded.Data != nil {
+		t.Fatalf("WRONG decoded event: %+v %v\n", decoded, err)
+	}
+	for _, invalid := range []string{`{"direction":"up"}`, `{"direction":"send","data":"xx"}`, `{"direction":"receive","duration":"1"}`} {
+		if err := json.Unmarshal([]byte(invalid), &decoded); err == nil {
+			t.Fatalf("decode %v NO ERROR\n", invalid)
+		}
+	}
+}
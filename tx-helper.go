@@ -1,15 +1,13 @@
 package ledger
 
 import (
-	"crypto/sha512"
+	"bytes"
 	"encoding/binary"
 	"encoding/hex"
 	"encoding/json"
 	"io/ioutil"
 	"os"
 	"testing"
-
-	"github.com/spacemeshos/ed25519"
 )
 
 // Transaction info struct
@@ -116,10 +114,9 @@ func testTx(t *testing.T, device *Ledger, txInfoFileName string, txType string,
 			callback(txInfo)
 		}
 		tx := createTx(txInfo)
-		response, err := device.SignTx(StringToPath("44'/540'/0'/0/0'"), tx)
+		signedTx, err := device.SignTx(StringToPath("44'/540'/0'/0/0'"), tx)
 		if err == nil {
-			hash := sha512.Sum512(tx)
-			if ed25519.Verify(publicKey, hash[:], response[1:65]) {
+			if signedTx.Type == TxType(txInfo.Type) && bytes.Equal(signedTx.PublicKey, publicKey) && signedTx.Verify() {
 				t.Logf("Verify %s tx: OK\n", txType)
 			} else {
 				t.Logf("Verify %s tx: FAILED\n", txType)
@@ -0,0 +1,338 @@
+package ledger
+
+import (
+	"bytes"
+	"crypto/sha512"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/spacemeshos/ed25519"
+)
+
+const (
+	// NetworkIDSize Size of the network identifier
+	NetworkIDSize = 32
+	// AddressSize Size of the account address
+	AddressSize = 20
+	// PublicKeySize Size of the ed25519 public key
+	PublicKeySize = 32
+	// SignatureSize Size of the ed25519 signature
+	SignatureSize = 64
+	// SignedTxSize Size of the encoded SignedTx: type, signature and public key
+	SignedTxSize = 1 + SignatureSize + PublicKeySize
+
+	// Size of the fixed transaction part: network id, type, nonce, recipient, gas limit, gas price, amount
+	cTxHeaderSize = NetworkIDSize + 1 + 8 + AddressSize + 8 + 8 + 8
+	// Offsets of the transaction fields
+	cTxTypeOffset      = NetworkIDSize
+	cTxNonceOffset     = cTxTypeOffset + 1
+	cTxRecipientOffset = cTxNonceOffset + 8
+	cTxGasLimitOffset  = cTxRecipientOffset + AddressSize
+	cTxGasPriceOffset  = cTxGasLimitOffset + 8
+	cTxAmountOffset    = cTxGasPriceOffset + 8
+)
+
+// TxType Spacemesh transaction type
+type TxType byte
+
+const (
+	// TxTypeCoin Coin transaction signed with ed25519
+	TxTypeCoin TxType = 0
+	// TxTypeExecApp Exec app transaction signed with ed25519
+	TxTypeExecApp TxType = 2
+	// TxTypeSpawnApp Spawn app transaction signed with ed25519
+	TxTypeSpawnApp TxType = 4
+	// TxTypeCoinPlus Coin transaction signed with ed25519++
+	TxTypeCoinPlus TxType = 1
+	// TxTypeExecAppPlus Exec app transaction signed with ed25519++
+	TxTypeExecAppPlus TxType = 3
+	// TxTypeSpawnAppPlus Spawn app transaction signed with ed25519++
+	TxTypeSpawnAppPlus TxType = 5
+)
+
+// String Transaction type as shown by the device
+func (txType TxType) String() string {
+	switch txType {
+	case TxTypeCoin:
+		return "COIN ED"
+	case TxTypeExecApp:
+		return "EXEC APP ED"
+	case TxTypeSpawnApp:
+		return "SPAWN APP ED"
+	case TxTypeCoinPlus:
+		return "COIN ED++"
+	case TxTypeExecAppPlus:
+		return "EXEC APP ED++"
+	case TxTypeSpawnAppPlus:
+		return "SPAWN APP ED++"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// IsEdPlus Reports whether the transaction is signed with ed25519++:
+// the signer public key is not included and is recovered from the signature.
+func (txType TxType) IsEdPlus() bool {
+	return txType&1 == 1
+}
+
+// Transaction type signed with ed25519 or ed25519++
+func (txType TxType) withEdPlus(edPlus bool) TxType {
+	if edPlus {
+		return txType | 1
+	}
+	return txType
+}
+
+// Tx Spacemesh transaction which can be signed by the device
+type Tx interface {
+	// Type Transaction type
+	Type() TxType
+	// Encode Encode transaction to bytes expected by SignTx
+	Encode() ([]byte, error)
+	// Header Fields common to all transaction types
+	Header() *TxHeader
+}
+
+// TxHeader Fields common to all transaction types
+type TxHeader struct {
+	// Network identifier, 32 bytes
+	NetworkID []byte
+	Nonce     uint64
+	// Recipient address, 20 bytes
+	Recipient []byte
+	GasLimit  uint64
+	GasPrice  uint64
+	// Amount in smidge
+	Amount uint64
+	// Signer public key, 32 bytes, not used by ed25519++ transactions
+	PublicKey []byte
+	// Sign with ed25519++, the signer public key is recovered from the signature
+	EdPlus bool
+}
+
+// Header Returns the header itself, promoted to the transaction types
+func (header *TxHeader) Header() *TxHeader {
+	return header
+}
+
+// CoinTx Coin transaction
+type CoinTx struct {
+	TxHeader
+}
+
+// ExecAppTx Exec app transaction
+type ExecAppTx struct {
+	TxHeader
+	// App call data
+	Data []byte
+}
+
+// SpawnAppTx Spawn app transaction
+type SpawnAppTx struct {
+	TxHeader
+	// App spawn data
+	Data []byte
+}
+
+// Type Returns TxTypeCoin or TxTypeCoinPlus
+func (tx *CoinTx) Type() TxType {
+	return TxTypeCoin.withEdPlus(tx.EdPlus)
+}
+
+// Encode Encode coin transaction
+func (tx *CoinTx) Encode() ([]byte, error) {
+	return encodeTx(tx.Type(), &tx.TxHeader, nil)
+}
+
+// Type Returns TxTypeExecApp or TxTypeExecAppPlus
+func (tx *ExecAppTx) Type() TxType {
+	return TxTypeExecApp.withEdPlus(tx.EdPlus)
+}
+
+// Encode Encode exec app transaction
+func (tx *ExecAppTx) Encode() ([]byte, error) {
+	return encodeTx(tx.Type(), &tx.TxHeader, tx.Data)
+}
+
+// Type Returns TxTypeSpawnApp or TxTypeSpawnAppPlus
+func (tx *SpawnAppTx) Type() TxType {
+	return TxTypeSpawnApp.withEdPlus(tx.EdPlus)
+}
+
+// Encode Encode spawn app transaction
+func (tx *SpawnAppTx) Encode() ([]byte, error) {
+	return encodeTx(tx.Type(), &tx.TxHeader, tx.Data)
+}
+
+// Validate field lengths of the transaction header
+func (header *TxHeader) validate() error {
+	if len(header.NetworkID) != NetworkIDSize {
+		return fmt.Errorf("Wrong network id length: expected %v, got %v", NetworkIDSize, len(header.NetworkID))
+	}
+	if len(header.Recipient) != AddressSize {
+		return fmt.Errorf("Wrong recipient length: expected %v, got %v", AddressSize, len(header.Recipient))
+	}
+	if !header.EdPlus && len(header.PublicKey) != PublicKeySize {
+		return fmt.Errorf("Wrong public key length: expected %v, got %v", PublicKeySize, len(header.PublicKey))
+	}
+	return nil
+}
+
+// Encode transaction: network id, type, nonce, recipient, gas limit, gas price, amount, data,
+// public key for ed25519 transactions
+func encodeTx(txType TxType, header *TxHeader, data []byte) ([]byte, error) {
+	if err := header.validate(); err != nil {
+		return nil, err
+	}
+	tx := make([]byte, cTxHeaderSize, cTxHeaderSize+len(data)+PublicKeySize)
+	copy(tx, header.NetworkID)
+	tx[cTxTypeOffset] = byte(txType)
+	binary.BigEndian.PutUint64(tx[cTxNonceOffset:], header.Nonce)
+	copy(tx[cTxRecipientOffset:], header.Recipient)
+	binary.BigEndian.PutUint64(tx[cTxGasLimitOffset:], header.GasLimit)
+	binary.BigEndian.PutUint64(tx[cTxGasPriceOffset:], header.GasPrice)
+	binary.BigEndian.PutUint64(tx[cTxAmountOffset:], header.Amount)
+	tx = append(tx, data...)
+	if !header.EdPlus {
+		tx = append(tx, header.PublicKey...)
+	}
+	return tx, nil
+}
+
+// DecodeTx Decode transaction bytes built by Encode or passed to SignTx.
+//
+// param {[]byte} data The encoded transaction: network id, type, nonce, recipient,
+// gas limit, gas price, amount, app data and signer public key for ed25519 transactions.
+// return {Tx} *CoinTx, *ExecAppTx or *SpawnAppTx.
+// return {error} Error value if the data is truncated, has unknown type or wrong length.
+//
+// example
+// tx, err := ledger.DecodeTx(data)
+//
+//	if err == nil {
+//		header := tx.Header()
+//		fmt.Printf("%v: send %v to %x\n", tx.Type(), header.Amount, header.Recipient)
+//	}
+func DecodeTx(data []byte) (Tx, error) {
+	edPlus := len(data) > cTxTypeOffset && TxType(data[cTxTypeOffset]).IsEdPlus()
+	keySize := PublicKeySize
+	if edPlus {
+		keySize = 0
+	}
+	if len(data) < cTxHeaderSize+keySize {
+		return nil, fmt.Errorf("Wrong transaction length: expected at least %v, got %v", cTxHeaderSize+keySize, len(data))
+	}
+	header := TxHeader{
+		NetworkID: append([]byte{}, data[:cTxTypeOffset]...),
+		Nonce:     binary.BigEndian.Uint64(data[cTxNonceOffset:]),
+		Recipient: append([]byte{}, data[cTxRecipientOffset:cTxGasLimitOffset]...),
+		GasLimit:  binary.BigEndian.Uint64(data[cTxGasLimitOffset:]),
+		GasPrice:  binary.BigEndian.Uint64(data[cTxGasPriceOffset:]),
+		Amount:    binary.BigEndian.Uint64(data[cTxAmountOffset:]),
+		EdPlus:    edPlus,
+	}
+	if !edPlus {
+		header.PublicKey = append([]byte{}, data[len(data)-PublicKeySize:]...)
+	}
+	appData := append([]byte{}, data[cTxHeaderSize:len(data)-keySize]...)
+
+	switch txType := TxType(data[cTxTypeOffset]); txType {
+	case TxTypeCoin, TxTypeCoinPlus:
+		if len(appData) != 0 {
+			return nil, fmt.Errorf("Wrong coin transaction length: expected %v, got %v", cTxHeaderSize+keySize, len(data))
+		}
+		return &CoinTx{TxHeader: header}, nil
+	case TxTypeExecApp, TxTypeExecAppPlus:
+		return &ExecAppTx{TxHeader: header, Data: appData}, nil
+	case TxTypeSpawnApp, TxTypeSpawnAppPlus:
+		return &SpawnAppTx{TxHeader: header, Data: appData}, nil
+	default:
+		return nil, fmt.Errorf("Unknown transaction type: %v", byte(txType))
+	}
+}
+
+// SignedTx Transaction signed by the device
+type SignedTx struct {
+	// Transaction type, taken from the signed payload
+	Type TxType
+	// Signature of the sha512 hash of the payload, 64 bytes
+	Signature []byte
+	// Signer public key returned by the device, 32 bytes
+	PublicKey []byte
+	// Signed payload as passed to SignTx
+	Tx []byte
+}
+
+// Bytes Encode signed transaction: type, signature, public key.
+// The payload is not included.
+func (signedTx *SignedTx) Bytes() []byte {
+	result := make([]byte, 0, SignedTxSize)
+	result = append(result, byte(signedTx.Type))
+	result = append(result, signedTx.Signature...)
+	result = append(result, signedTx.PublicKey...)
+	return result
+}
+
+// MarshalBinary Same as Bytes, implements encoding.BinaryMarshaler
+func (signedTx *SignedTx) MarshalBinary() ([]byte, error) {
+	if len(signedTx.Signature) != SignatureSize {
+		return nil, fmt.Errorf("Wrong signature length: expected %v, got %v", SignatureSize, len(signedTx.Signature))
+	}
+	if len(signedTx.PublicKey) != PublicKeySize {
+		return nil, fmt.Errorf("Wrong public key length: expected %v, got %v", PublicKeySize, len(signedTx.PublicKey))
+	}
+	return signedTx.Bytes(), nil
+}
+
+// UnmarshalBinary Decode signed transaction encoded by Bytes, implements encoding.BinaryUnmarshaler.
+// The payload is left empty.
+func (signedTx *SignedTx) UnmarshalBinary(data []byte) error {
+	if len(data) != SignedTxSize {
+		return fmt.Errorf("Wrong signed transaction length: expected %v, got %v", SignedTxSize, len(data))
+	}
+	signedTx.Type = TxType(data[0])
+	signedTx.Signature = append([]byte{}, data[1:1+SignatureSize]...)
+	signedTx.PublicKey = append([]byte{}, data[1+SignatureSize:]...)
+	signedTx.Tx = nil
+	return nil
+}
+
+// SignerPublicKey Public key of the signer: embedded at the end of the ed25519 payload,
+// recovered from the signature for ed25519++ transactions.
+//
+// return {[]byte} Signer public key.
+// return {error} Error value if the payload is too short or the key can not be recovered.
+func (signedTx *SignedTx) SignerPublicKey() ([]byte, error) {
+	if signedTx.Type.IsEdPlus() {
+		hash := sha512.Sum512(signedTx.Tx)
+		publicKey, err := ed25519.ExtractPublicKey(hash[:], signedTx.Signature)
+		if err != nil {
+			return nil, err
+		}
+		return publicKey, nil
+	}
+	if len(signedTx.Tx) < PublicKeySize {
+		return nil, fmt.Errorf("Wrong transaction length: expected at least %v, got %v", PublicKeySize, len(signedTx.Tx))
+	}
+	return signedTx.Tx[len(signedTx.Tx)-PublicKeySize:], nil
+}
+
+// Verify Verify the signature of the payload: ed25519 or ed25519++ signature of the sha512 hash
+// made by the public key, which must also be the signer key of the payload, see SignerPublicKey.
+//
+// return {bool} true if the signature is valid.
+func (signedTx *SignedTx) Verify() bool {
+	if len(signedTx.PublicKey) != PublicKeySize || len(signedTx.Signature) != SignatureSize {
+		return false
+	}
+	signer, err := signedTx.SignerPublicKey()
+	if err != nil || !bytes.Equal(signer, signedTx.PublicKey) {
+		return false
+	}
+	hash := sha512.Sum512(signedTx.Tx)
+	if signedTx.Type.IsEdPlus() {
+		return ed25519.Verify2(signedTx.PublicKey, hash[:], signedTx.Signature)
+	}
+	return ed25519.Verify(signedTx.PublicKey, hash[:], signedTx.Signature)
+}
@@ -0,0 +1,240 @@
+package ledger
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+// Convert test transaction info to typed transaction
+func txFromInfo(txInfo *txInfo) Tx {
+	header := TxHeader{
+		NetworkID: txInfo.NetworkID,
+		Nonce:     txInfo.Nonce,
+		Recipient: txInfo.To,
+		GasLimit:  txInfo.GasLimit,
+		GasPrice:  txInfo.GasPrice,
+		Amount:    txInfo.Amount,
+		PublicKey: txInfo.PublicKey,
+	}
+	switch TxType(txInfo.Type) {
+	case TxTypeExecApp:
+		return &ExecAppTx{TxHeader: header, Data: txInfo.Data}
+	case TxTypeSpawnApp:
+		return &SpawnAppTx{TxHeader: header, Data: txInfo.Data}
+	default:
+		return &CoinTx{TxHeader: header}
+	}
+}
+
+func TestTxEncode(t *testing.T) {
+	publicKey, _ := hex.DecodeString(testPublicKey)
+	for _, fileName := range []string{"coin.tx.json", "app.tx.json", "spawn.tx.json"} {
+		txInfo, err := loadTxInfo(fileName)
+		if err != nil {
+			t.Fatalf("%v: load tx info ERROR: %v\n", fileName, err)
+		}
+		txInfo.PublicKey = publicKey
+		tx := txFromInfo(txInfo)
+		if tx.Type() != TxType(txInfo.Type) {
+			t.Fatalf("%v: WRONG tx type %v\n", fileName, tx.Type())
+		}
+		encoded, err := tx.Encode()
+		if err != nil {
+			t.Fatalf("%v: encode ERROR: %v\n", fileName, err)
+		}
+		if !bytes.Equal(encoded, createTx(txInfo)) {
+			t.Fatalf("%v: encoded tx does not match:\n%x\n%x\n", fileName, encoded, createTx(txInfo))
+		}
+	}
+}
+
+func TestTxEncodeValidation(t *testing.T) {
+	valid := TxHeader{
+		NetworkID: make([]byte, NetworkIDSize),
+		Recipient: make([]byte, AddressSize),
+		PublicKey: make([]byte, PublicKeySize),
+	}
+	if _, err := (&CoinTx{TxHeader: valid}).Encode(); err != nil {
+		t.Fatalf("encode ERROR: %v\n", err)
+	}
+
+	networkID := valid
+	networkID.NetworkID = make([]byte, 31)
+	recipient := valid
+	recipient.Recipient = make([]byte, 32)
+	publicKey := valid
+	publicKey.PublicKey = nil
+	tests := []struct {
+		tx      Tx
+		message string
+	}{
+		{&CoinTx{TxHeader: networkID}, "Wrong network id length: expected 32, got 31"},
+		{&ExecAppTx{TxHeader: recipient}, "Wrong recipient length: expected 20, got 32"},
+		{&SpawnAppTx{TxHeader: publicKey}, "Wrong public key length: expected 32, got 0"},
+	}
+	for _, test := range tests {
+		if _, err := test.tx.Encode(); err == nil || err.Error() != test.message {
+			t.Errorf("%v: WRONG validation error: %v\n", test.tx.Type(), err)
+		}
+	}
+}
+
+func TestDecodeTx(t *testing.T) {
+	publicKey, _ := hex.DecodeString(testPublicKey)
+	for _, fileName := range []string{"coin.tx.json", "app.tx.json", "spawn.tx.json"} {
+		txInfo, err := loadTxInfo(fileName)
+		if err != nil {
+			t.Fatalf("%v: load tx info ERROR: %v\n", fileName, err)
+		}
+		txInfo.PublicKey = publicKey
+		data := createTx(txInfo)
+		tx, err := DecodeTx(data)
+		if err != nil {
+			t.Fatalf("%v: decode ERROR: %v\n", fileName, err)
+		}
+		if tx.Type() != TxType(txInfo.Type) {
+			t.Fatalf("%v: WRONG tx type %v\n", fileName, tx.Type())
+		}
+		header := tx.Header()
+		if header.Nonce != txInfo.Nonce || header.GasLimit != txInfo.GasLimit ||
+			header.GasPrice != txInfo.GasPrice || header.Amount != txInfo.Amount ||
+			!bytes.Equal(header.Recipient, txInfo.To) || !bytes.Equal(header.PublicKey, publicKey) {
+			t.Fatalf("%v: WRONG decoded header %+v\n", fileName, header)
+		}
+		encoded, err := tx.Encode()
+		if err != nil || !bytes.Equal(encoded, data) {
+			t.Fatalf("%v: re-encoded tx does not match: %v\n", fileName, err)
+		}
+	}
+}
+
+func TestDecodeTxErrors(t *testing.T) {
+	coin := make([]byte, cTxHeaderSize+PublicKeySize)
+	unknown := append([]byte{}, coin...)
+	unknown[cTxTypeOffset] = 7
+	tests := []struct {
+		data    []byte
+		message string
+	}{
+		{nil, "Wrong transaction length: expected at least 117, got 0"},
+		{coin[:100], "Wrong transaction length: expected at least 117, got 100"},
+		{append(coin, 0), "Wrong coin transaction length: expected 117, got 118"},
+		{unknown, "Unknown transaction type: 7"},
+	}
+	for _, test := range tests {
+		if _, err := DecodeTx(test.data); err == nil || err.Error() != test.message {
+			t.Errorf("WRONG decode error: %v, expected: %v\n", err, test.message)
+		}
+	}
+
+	device := NewLedger(newTestEmulator(t))
+	if _, err := device.SignTx(StringToPath("44'/540'/0'/0/0'"), unknown); err == nil || err.Error() != "Unknown transaction type: 7" {
+		t.Fatalf("WRONG sign error: %v\n", err)
+	}
+}
+
+func TestSignedTx(t *testing.T) {
+	device := NewLedger(newTestEmulator(t))
+	publicKey, _ := hex.DecodeString(testPublicKey)
+	txInfo, err := loadTxInfo("spawn.tx.json")
+	if err != nil {
+		t.Fatalf("load tx info ERROR: %v\n", err)
+	}
+	txInfo.PublicKey = publicKey
+	txInfo.Nonce = 0x0100000000000000
+	tx := createTx(txInfo)
+	signedTx, err := device.SignTx(StringToPath("44'/540'/0'/0/0'"), tx)
+	if err != nil {
+		t.Fatalf("sign tx ERROR: %v\n", err)
+	}
+	if signedTx.Type != TxTypeSpawnApp || !bytes.Equal(signedTx.PublicKey, publicKey) || !bytes.Equal(signedTx.Tx, tx) {
+		t.Fatalf("WRONG signed tx %+v\n", signedTx)
+	}
+	if !signedTx.Verify() {
+		t.Fatalf("signed tx verification FAILED\n")
+	}
+
+	data, err := signedTx.MarshalBinary()
+	if err != nil || len(data) != SignedTxSize || !bytes.Equal(data, signedTx.Bytes()) {
+		t.Fatalf("marshal ERROR: %v\n", err)
+	}
+	var decoded SignedTx
+	if err := decoded.UnmarshalBinary(data); err != nil {
+		t.Fatalf("unmarshal ERROR: %v\n", err)
+	}
+	if decoded.Type != signedTx.Type || !bytes.Equal(decoded.Signature, signedTx.Signature) || !bytes.Equal(decoded.PublicKey, signedTx.PublicKey) {
+		t.Fatalf("WRONG unmarshaled tx %+v\n", decoded)
+	}
+	if decoded.Verify() {
+		t.Fatalf("signed tx without payload verified\n")
+	}
+	if err := decoded.UnmarshalBinary(data[1:]); err == nil {
+		t.Fatalf("unmarshal of short data succeeded\n")
+	}
+
+	signedTx.Tx[cTxAmountOffset] ^= 1
+	if signedTx.Verify() {
+		t.Fatalf("tampered tx verified\n")
+	}
+}
+
+func TestEdPlusTx(t *testing.T) {
+	device := NewLedger(newTestEmulator(t))
+	device.SetVerifySignatures(true)
+	publicKey, _ := hex.DecodeString(testPublicKey)
+	header := TxHeader{
+		NetworkID: make([]byte, NetworkIDSize),
+		Nonce:     5,
+		Recipient: make([]byte, AddressSize),
+		GasLimit:  1000,
+		GasPrice:  1,
+		Amount:    1000000000,
+		EdPlus:    true,
+	}
+	txs := []struct {
+		tx       Tx
+		expected TxType
+	}{
+		{&CoinTx{TxHeader: header}, TxTypeCoinPlus},
+		{&ExecAppTx{TxHeader: header, Data: []byte{1, 2, 3}}, TxTypeExecAppPlus},
+		{&SpawnAppTx{TxHeader: header, Data: make([]byte, 300)}, TxTypeSpawnAppPlus},
+	}
+	for _, test := range txs {
+		if test.tx.Type() != test.expected || !test.expected.IsEdPlus() {
+			t.Fatalf("WRONG tx type %v, expected %v\n", test.tx.Type(), test.expected)
+		}
+		data, err := test.tx.Encode()
+		if err != nil {
+			t.Fatalf("%v: encode ERROR: %v\n", test.expected, err)
+		}
+		decoded, err := DecodeTx(data)
+		if err != nil || decoded.Type() != test.expected || decoded.Header().PublicKey != nil {
+			t.Fatalf("%v: decode ERROR: %v\n", test.expected, err)
+		}
+
+		signedTx, err := device.SignTx(StringToPath("44'/540'/0'/0/0'"), data)
+		if err != nil {
+			t.Fatalf("%v: sign ERROR: %v\n", test.expected, err)
+		}
+		signer, err := signedTx.SignerPublicKey()
+		if err != nil || !bytes.Equal(signer, publicKey) {
+			t.Fatalf("%v: WRONG recovered signer %x: %v\n", test.expected, signer, err)
+		}
+		if signedTx.Type != test.expected || !signedTx.Verify() {
+			t.Fatalf("%v: signed tx verification FAILED\n", test.expected)
+		}
+		signedTx.Tx[cTxAmountOffset] ^= 1
+		if signedTx.Verify() {
+			t.Fatalf("%v: tampered tx verified\n", test.expected)
+		}
+	}
+
+	coin, _ := (&CoinTx{TxHeader: header}).Encode()
+	if _, err := DecodeTx(append(coin, 0)); err == nil || err.Error() != "Wrong coin transaction length: expected 85, got 86" {
+		t.Fatalf("WRONG decode error: %v\n", err)
+	}
+	if _, err := DecodeTx(coin[:84]); err == nil || err.Error() != "Wrong transaction length: expected at least 85, got 84" {
+		t.Fatalf("WRONG decode error: %v\n", err)
+	}
+}
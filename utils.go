@@ -2,35 +2,91 @@ package ledger
 
 import (
 	"encoding/binary"
+	"fmt"
 	"strconv"
 	"strings"
 )
 
-// StringToPath Parse string to BIP32 path
+// Hardened Offset of hardened BIP32 path indexes
+const Hardened = 0x80000000
+
+// StringToPath Parse string to BIP32 path, returns nil if the string is not a valid path.
+// See ParseBipPath for the accepted format.
 func StringToPath(pathStr string) BipPath {
-	if len(pathStr) == 0 {
+	path, err := ParseBipPath(pathStr)
+	if err != nil {
 		return nil
 	}
+	return path
+}
 
+// ParseBipPath Parse string to BIP32 path.
+//
+// param {string} pathStr Path indexes separated by slash with optional `m/` prefix,
+// hardened indexes are marked with `'`, `h` or `H` suffix, e.g. "m/44'/540'/0'/0/0'" or "44h/540h/0h/0/0h".
+// return {BipPath} Parsed path.
+// return {error} Error value with the offending component.
+//
+// example
+// path, err := ledger.ParseBipPath("m/44'/540'/0'/0/0'")
+//
+//	if err != nil {
+//		fmt.Printf("parse path ERROR: %v\n", err)
+//	}
+func ParseBipPath(pathStr string) (BipPath, error) {
+	if len(pathStr) == 0 {
+		return nil, fmt.Errorf("Path is empty")
+	}
 	items := strings.Split(pathStr, "/")
+	if items[0] == "m" || items[0] == "M" {
+		items = items[1:]
+	}
+
 	path := make(BipPath, len(items))
-	for i := 0; i < len(items); i++ {
-		var p uint64
+	for i, item := range items {
+		number := item
 		var base uint32
-		var err error
-		if strings.HasSuffix(items[i], "'") {
-			p, err = strconv.ParseUint(items[i][:len(items[i])-1], 10, 32)
-			base = 0x80000000
-		} else {
-			p, err = strconv.ParseUint(items[i], 10, 32)
+		if strings.HasSuffix(item, "'") || strings.HasSuffix(item, "h") || strings.HasSuffix(item, "H") {
+			number = item[:len(item)-1]
+			base = Hardened
+		}
+		if len(number) == 0 || strings.IndexFunc(number, func(r rune) bool { return r < '0' || r > '9' }) >= 0 {
+			return nil, fmt.Errorf("Invalid path component %q at index %v: expected number with optional ', h or H suffix", item, i)
 		}
-		if err != nil {
-			return nil
+		p, err := strconv.ParseUint(number, 10, 32)
+		if err != nil || p >= Hardened {
+			return nil, fmt.Errorf("Invalid path component %q at index %v: value is out of range", item, i)
 		}
 		path[i] = base + uint32(p)
 	}
+	return path, nil
+}
 
-	return path
+// ValidateSpacemeshPath Check that the path is accepted by the Spacemesh app:
+// 5 indexes `44'/540'/account'/change/index'` with hardened account and address index.
+//
+// param {BipPath} path The BIP 32 path indexes.
+// return {error} Error value describing the first violated rule.
+func ValidateSpacemeshPath(path BipPath) error {
+	if len(path) != 5 {
+		return fmt.Errorf("Wrong path length of %v: expected 5 indexes, got %v", path, len(path))
+	}
+	if path[0] != 44|Hardened {
+		return fmt.Errorf("Wrong purpose of path %v: expected 44'", path)
+	}
+	if path[1] != 540|Hardened {
+		return fmt.Errorf("Wrong coin type of path %v: expected 540'", path)
+	}
+	if path[2]&Hardened == 0 {
+		return fmt.Errorf("Wrong account of path %v: index must be hardened", path)
+	}
+	if path[3]&Hardened != 0 {
+		return fmt.Errorf("Wrong change of path %v: index must not be hardened", path)
+	}
+	if path[4]&Hardened == 0 {
+		return fmt.Errorf("Wrong address index of path %v: index must be hardened", path)
+	}
+	return nil
 }
 
 // Convert PIB32 path to BE bytes array
@@ -43,3 +99,104 @@ func pathToBytes(path BipPath) []byte {
 	}
 	return data
 }
+
+// Convert BE bytes array to BIP32 path
+// return {BipPath} Parsed path
+// return {int} Number of consumed bytes
+// return {error} Error value.
+func bytesToPath(data []byte) (BipPath, int, error) {
+	if len(data) < 1 {
+		return nil, 0, fmt.Errorf("Path length is missing")
+	}
+	count := int(data[0])
+	if count > cMaxPathLength {
+		return nil, 0, fmt.Errorf("Path is too long: %v indexes", count)
+	}
+	size := 1 + 4*count
+	if len(data) < size {
+		return nil, 0, fmt.Errorf("Path is truncated: expected %v bytes, got %v", size, len(data))
+	}
+	path := make(BipPath, count)
+	for i := range path {
+		path[i] = binary.BigEndian.Uint32(data[1+i*4:])
+	}
+	return path, size, nil
+}
+
+// String Format BIP32 path, e.g. m/44'/540'/0'/0/0'
+func (path BipPath) String() string {
+	var sb strings.Builder
+	sb.WriteString("m")
+	for _, p := range path {
+		sb.WriteString("/")
+		sb.WriteString(strconv.FormatUint(uint64(p&^Hardened), 10))
+		if p&Hardened != 0 {
+			sb.WriteString("'")
+		}
+	}
+	return sb.String()
+}
+
+// MarshalText Same as String, implements encoding.TextMarshaler
+func (path BipPath) MarshalText() ([]byte, error) {
+	return []byte(path.String()), nil
+}
+
+// UnmarshalText Parse path with ParseBipPath, implements encoding.TextUnmarshaler
+func (path *BipPath) UnmarshalText(text []byte) error {
+	parsed, err := ParseBipPath(string(text))
+	if err != nil {
+		return err
+	}
+	*path = parsed
+	return nil
+}
+
+// Equal Reports whether both paths have the same indexes
+func (path BipPath) Equal(other BipPath) bool {
+	if len(path) != len(other) {
+		return false
+	}
+	for i := range path {
+		if path[i] != other[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Child Returns new path extended by the index, add Hardened for hardened index.
+//
+// example
+// path := ledger.SpacemeshPath(0, 0, 0).Parent().Child(ledger.Hardened + 1) // m/44'/540'/0'/0/1'
+func (path BipPath) Child(index uint32) BipPath {
+	child := make(BipPath, len(path), len(path)+1)
+	copy(child, path)
+	return append(child, index)
+}
+
+// Parent Returns new path without the last index, nil for empty path
+func (path BipPath) Parent() BipPath {
+	if len(path) == 0 {
+		return nil
+	}
+	return append(BipPath{}, path[:len(path)-1]...)
+}
+
+// IsHardened Reports whether the i-th index of the path is hardened
+func (path BipPath) IsHardened(i int) bool {
+	return i >= 0 && i < len(path) && path[i]&Hardened != 0
+}
+
+// SpacemeshPath Create path `44'/540'/account'/change/index'` accepted by ValidateSpacemeshPath.
+//
+// param {uint32} account Account index, hardened.
+// param {uint32} change Change index, not hardened.
+// param {uint32} index Address index, hardened.
+// return {BipPath} The path.
+//
+// example
+// address, err := device.GetAddress(ledger.SpacemeshPath(0, 0, 1)) // m/44'/540'/0'/0/1'
+func SpacemeshPath(account, change, index uint32) BipPath {
+	return BipPath{44 | Hardened, 540 | Hardened, account | Hardened, change &^ Hardened, index | Hardened}
+}
@@ -0,0 +1,115 @@
+package ledger
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestParseBipPath(t *testing.T) {
+	expected := BipPath{44 | Hardened, 540 | Hardened, 0 | Hardened, 0, 1 | Hardened}
+	for _, pathStr := range []string{"44'/540'/0'/0/1'", "m/44'/540'/0'/0/1'", "44h/540h/0h/0/1h", "M/44H/540H/0'/0/1h"} {
+		path, err := ParseBipPath(pathStr)
+		if err != nil {
+			t.Fatalf("%v: parse ERROR: %v\n", pathStr, err)
+		}
+		if !reflect.DeepEqual(path, expected) {
+			t.Fatalf("%v: WRONG path %v\n", pathStr, path)
+		}
+	}
+
+	tests := []struct {
+		pathStr string
+		message string
+	}{
+		{"", "Path is empty"},
+		{"44'/540'//0", "Invalid path component \"\" at index 2: expected number with optional ', h or H suffix"},
+		{"m/44'/x540'", "Invalid path component \"x540'\" at index 1: expected number with optional ', h or H suffix"},
+		{"44'/540''", "Invalid path component \"540''\" at index 1: expected number with optional ', h or H suffix"},
+		{"44'/-1", "Invalid path component \"-1\" at index 1: expected number with optional ', h or H suffix"},
+		{"44'/2147483648'", "Invalid path component \"2147483648'\" at index 1: value is out of range"},
+		{"44'/99999999999", "Invalid path component \"99999999999\" at index 1: value is out of range"},
+	}
+	for _, test := range tests {
+		if _, err := ParseBipPath(test.pathStr); err == nil || err.Error() != test.message {
+			t.Errorf("%q: WRONG parse error: %v\n", test.pathStr, err)
+		}
+		if StringToPath(test.pathStr) != nil {
+			t.Errorf("%q: StringToPath should return nil\n", test.pathStr)
+		}
+	}
+}
+
+func TestValidateSpacemeshPath(t *testing.T) {
+	for _, pathStr := range []string{"44'/540'/0'/0/0'", "44'/540'/7'/1/2147483647'"} {
+		if err := ValidateSpacemeshPath(StringToPath(pathStr)); err != nil {
+			t.Fatalf("%v: validation ERROR: %v\n", pathStr, err)
+		}
+	}
+
+	tests := []struct {
+		pathStr string
+		message string
+	}{
+		{"m", "Wrong path length of m: expected 5 indexes, got 0"},
+		{"44'/540'/0'/0", "Wrong path length of m/44'/540'/0'/0: expected 5 indexes, got 4"},
+		{"44/540'/0'/0/0'", "Wrong purpose of path m/44/540'/0'/0/0': expected 44'"},
+		{"44'/60'/0'/0/0'", "Wrong coin type of path m/44'/60'/0'/0/0': expected 540'"},
+		{"44'/540'/0/0/0'", "Wrong account of path m/44'/540'/0/0/0': index must be hardened"},
+		{"44'/540'/0'/0'/0'", "Wrong change of path m/44'/540'/0'/0'/0': index must not be hardened"},
+		{"44'/540'/0'/0/0", "Wrong address index of path m/44'/540'/0'/0/0: index must be hardened"},
+	}
+	for _, test := range tests {
+		if err := ValidateSpacemeshPath(StringToPath(test.pathStr)); err == nil || err.Error() != test.message {
+			t.Errorf("%v: WRONG validation error: %v\n", test.pathStr, err)
+		}
+	}
+
+	// Invalid path is rejected before any request is sent to the device
+	device := NewLedger(&staticDevice{})
+	if _, err := device.GetExtendedPublicKey(StringToPath("44'/540'/0'")); err == nil || err.Error() != "Wrong path length of m/44'/540'/0': expected 5 indexes, got 3" {
+		t.Fatalf("WRONG get public key error: %v\n", err)
+	}
+}
+
+func TestBipPath(t *testing.T) {
+	path := SpacemeshPath(0, 0, 0)
+	if path.String() != "m/44'/540'/0'/0/0'" || !path.Equal(StringToPath("44'/540'/0'/0/0'")) {
+		t.Fatalf("WRONG path %v\n", path)
+	}
+	if err := ValidateSpacemeshPath(SpacemeshPath(3, 1, 7)); err != nil {
+		t.Fatalf("validation ERROR: %v\n", err)
+	}
+	if !path.IsHardened(2) || path.IsHardened(3) || !path.IsHardened(4) || path.IsHardened(5) || path.IsHardened(-1) {
+		t.Fatalf("WRONG hardened indexes of %v\n", path)
+	}
+
+	parent := path.Parent()
+	if parent.String() != "m/44'/540'/0'/0" || path.String() != "m/44'/540'/0'/0/0'" {
+		t.Fatalf("WRONG parent %v of %v\n", parent, path)
+	}
+	sibling := parent.Child(Hardened + 1)
+	other := parent.Child(2)
+	if sibling.String() != "m/44'/540'/0'/0/1'" || other.String() != "m/44'/540'/0'/0/2" || sibling.Equal(path) {
+		t.Fatalf("WRONG children %v, %v\n", sibling, other)
+	}
+	if BipPath(nil).Parent() != nil || BipPath(nil).String() != "m" {
+		t.Fatalf("WRONG empty path\n")
+	}
+
+	var config struct {
+		Path BipPath `json:"path"`
+	}
+	config.Path = path
+	data, err := json.Marshal(config)
+	if err != nil || string(data) != `{"path":"m/44'/540'/0'/0/0'"}` {
+		t.Fatalf("marshal ERROR: %v, %s\n", err, data)
+	}
+	config.Path = nil
+	if err := json.Unmarshal(data, &config); err != nil || !config.Path.Equal(path) {
+		t.Fatalf("unmarshal ERROR: %v, %v\n", err, config.Path)
+	}
+	if err := json.Unmarshal([]byte(`{"path":"m/44'/x"}`), &config); err == nil {
+		t.Fatalf("unmarshal of invalid path succeeded\n")
+	}
+}
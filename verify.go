@@ -0,0 +1,64 @@
+package ledger
+
+import (
+	"bytes"
+)
+
+// SetVerifySignatures Enable host-side verification of signatures returned by SignTx.
+// The signature must verify against the signer public key embedded at the end of the
+// transaction or recovered from the ed25519++ signature. The device must return the
+// same key and, if GetExtendedPublicKey was called for the signing path, the key
+// reported by it. Otherwise SignTx returns *VerificationError and no signature.
+//
+// Only the signature validity is checked unless GetExtendedPublicKey was called for
+// the signing path on this Ledger before. A different device or account is detected
+// only by that key comparison, the key is not fetched by SignTx.
+//
+// param {bool} verify true to enable verification.
+func (device *Ledger) SetVerifySignatures(verify bool) {
+	device.mutex.Lock()
+	defer device.mutex.Unlock()
+	device.verifySignatures = verify
+}
+
+// Remember public key reported by the device for the path
+func (device *Ledger) rememberPublicKey(path BipPath, publicKey []byte) {
+	device.mutex.Lock()
+	defer device.mutex.Unlock()
+	if device.publicKeys == nil {
+		device.publicKeys = make(map[string][]byte)
+	}
+	device.publicKeys[path.String()] = append([]byte{}, publicKey...)
+}
+
+// Check signed transaction if verification is enabled
+func (device *Ledger) verify(path BipPath, signedTx *SignedTx) error {
+	device.mutex.Lock()
+	verify := device.verifySignatures
+	knownKey, known := device.publicKeys[path.String()]
+	device.mutex.Unlock()
+	if !verify {
+		return nil
+	}
+
+	// The rejected signature is not kept in the error
+	verificationErr := &VerificationError{Path: path, Type: signedTx.Type, PublicKey: signedTx.PublicKey}
+	signer, err := signedTx.SignerPublicKey()
+	if err != nil {
+		verificationErr.Err = ErrInvalidSignature
+		return verificationErr
+	}
+	if !bytes.Equal(signedTx.PublicKey, signer) {
+		verificationErr.ExpectedPublicKey, verificationErr.Err = signer, ErrPublicKeyMismatch
+		return verificationErr
+	}
+	if known && !bytes.Equal(signedTx.PublicKey, knownKey) {
+		verificationErr.ExpectedPublicKey, verificationErr.Err = knownKey, ErrPublicKeyMismatch
+		return verificationErr
+	}
+	if !signedTx.Verify() {
+		verificationErr.Err = ErrInvalidSignature
+		return verificationErr
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package ledger
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"testing"
+)
+
+// Emulator with corrupted responses
+type tamperingDevice struct {
+	*Emulator
+	tamper func(apdu []byte, response []byte)
+}
+
+func (device *tamperingDevice) Exchange(apdu []byte) ([]byte, error) {
+	response, err := device.Emulator.Exchange(apdu)
+	if err == nil && len(response) > 2 {
+		device.tamper(apdu, response[:len(response)-2])
+	}
+	return response, err
+}
+
+func TestVerifySignatures(t *testing.T) {
+	publicKey, _ := hex.DecodeString(testPublicKey)
+	path := StringToPath("44'/540'/0'/0/0'")
+	tx, err := (&CoinTx{TxHeader: TxHeader{
+		NetworkID: make([]byte, NetworkIDSize),
+		Recipient: make([]byte, AddressSize),
+		Amount:    1000000000000,
+		PublicKey: publicKey,
+	}}).Encode()
+	if err != nil {
+		t.Fatalf("encode tx ERROR: %v\n", err)
+	}
+
+	tests := []struct {
+		name   string
+		tamper func(apdu []byte, response []byte)
+		target error
+	}{
+		{"valid", func(apdu []byte, response []byte) {}, nil},
+		{"signature", func(apdu []byte, response []byte) {
+			if apdu[1] == cInsSignTx {
+				response[0] ^= 1
+			}
+		}, ErrInvalidSignature},
+		{"signer", func(apdu []byte, response []byte) {
+			if apdu[1] == cInsSignTx {
+				response[SignatureSize] ^= 1
+			}
+		}, ErrPublicKeyMismatch},
+		{"exported key", func(apdu []byte, response []byte) {
+			if apdu[1] == cInsGetExtPublicKey {
+				response[0] ^= 1
+			}
+		}, ErrPublicKeyMismatch},
+	}
+	for _, test := range tests {
+		device := NewLedger(&tamperingDevice{Emulator: newTestEmulator(t), tamper: test.tamper})
+		if _, err := device.GetExtendedPublicKey(path); err != nil {
+			t.Fatalf("%v: get public key ERROR: %v\n", test.name, err)
+		}
+
+		// Verification is disabled by default
+		if _, err := device.SignTx(path, tx); err != nil {
+			t.Fatalf("%v: sign ERROR without verification: %v\n", test.name, err)
+		}
+
+		device.SetVerifySignatures(true)
+		signedTx, err := device.SignTx(path, tx)
+		if test.target == nil {
+			if err != nil || !signedTx.Verify() {
+				t.Fatalf("%v: sign ERROR: %v\n", test.name, err)
+			}
+			continue
+		}
+		var verificationErr *VerificationError
+		if signedTx != nil || !errors.As(err, &verificationErr) || !errors.Is(err, test.target) {
+			t.Fatalf("%v: WRONG verification error: %v\n", test.name, err)
+		}
+		if verificationErr.Path.String() != "m/44'/540'/0'/0/0'" || verificationErr.Type != TxTypeCoin ||
+			len(verificationErr.PublicKey) != PublicKeySize {
+			t.Fatalf("%v: WRONG verification error details: %+v\n", test.name, verificationErr)
+		}
+		mismatch := test.target == ErrPublicKeyMismatch
+		if mismatch != (verificationErr.ExpectedPublicKey != nil) || bytes.Equal(verificationErr.ExpectedPublicKey, verificationErr.PublicKey) {
+			t.Fatalf("%v: WRONG expected public key: %x\n", test.name, verificationErr.ExpectedPublicKey)
+		}
+	}
+}
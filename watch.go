@@ -0,0 +1,218 @@
+package ledger
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	// Default interval of device enumeration
+	cWatchInterval = 500 * time.Millisecond
+)
+
+// DeviceEventType Type of the device event
+type DeviceEventType int
+
+const (
+	// DeviceConnected Device was connected
+	DeviceConnected DeviceEventType = iota
+	// DeviceDisconnected Device was disconnected, the device is closed
+	DeviceDisconnected
+	// DeviceChanged Device was re-enumerated with another USB product id or path,
+	// e.g. when an app is opened or closed on newer firmware. The previous device is closed.
+	DeviceChanged
+	// DeviceEnumerationFailed Devices could not be enumerated, watching continues
+	DeviceEnumerationFailed
+)
+
+// String Event type name
+func (eventType DeviceEventType) String() string {
+	switch eventType {
+	case DeviceConnected:
+		return "connected"
+	case DeviceDisconnected:
+		return "disconnected"
+	case DeviceChanged:
+		return "changed"
+	case DeviceEnumerationFailed:
+		return "enumeration failed"
+	default:
+		return "unknown"
+	}
+}
+
+// DeviceEvent Hot-plug event
+type DeviceEvent struct {
+	Type DeviceEventType
+	// The connected, changed or disconnected device.
+	// The same value is reported for the device until it is changed or disconnected.
+	Device *Ledger
+	// Device info before the change, for DeviceChanged only
+	Previous *HidDeviceInfo
+	// Enumeration error, for DeviceEnumerationFailed only
+	Err error
+}
+
+// Watcher Hot-plug watcher polling the enumerator
+type Watcher struct {
+	// Source of devices, nil for HidEnumerator
+	Enumerator Enumerator
+	// Interval of enumeration, 500 ms if zero
+	Interval time.Duration
+}
+
+// Watch Watch connected USB devices, see Watcher.Watch
+func Watch(ctx context.Context) <-chan DeviceEvent {
+	return (&Watcher{}).Watch(ctx)
+}
+
+// Watch Emit events for devices connected when watching starts and for the later
+// connections, disconnections and changes. The channel is closed when the context is done.
+//
+// param {context.Context} ctx Context to stop watching.
+// return {<-chan DeviceEvent} Device events.
+//
+// example
+// for event := range ledger.Watch(ctx) {
+//
+//		if event.Type == ledger.DeviceConnected {
+//			fmt.Printf("connected %v\n", event.Device.GetHidInfo().Product)
+//		}
+//	}
+func (watcher *Watcher) Watch(ctx context.Context) <-chan DeviceEvent {
+	enumerator := watcher.Enumerator
+	if enumerator == nil {
+		enumerator = &HidEnumerator{}
+	}
+	interval := watcher.Interval
+	if interval <= 0 {
+		interval = cWatchInterval
+	}
+
+	events := make(chan DeviceEvent)
+	go func() {
+		defer close(events)
+		emit := func(event DeviceEvent) bool {
+			select {
+			case events <- event:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		known := make(map[string]*Ledger)
+		for {
+			devices, err := enumerator.Enumerate()
+			if err != nil {
+				if !emit(DeviceEvent{Type: DeviceEnumerationFailed, Err: err}) {
+					return
+				}
+			} else if !watcher.update(known, devices, enumerator, emit) {
+				return
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(interval):
+			}
+		}
+	}()
+	return events
+}
+
+// Compare enumerated devices with the known ones and emit the events,
+// returns false if the context is done
+func (watcher *Watcher) update(known map[string]*Ledger, devices []*Ledger, enumerator Enumerator, emit func(event DeviceEvent) bool) bool {
+	sortDevices(devices)
+	// Devices are identified by path, the same path is the same device
+	present := make(map[string]*Ledger, len(devices))
+	appeared := make([]*Ledger, 0)
+	for _, device := range devices {
+		path := device.GetHidInfo().Path
+		if _, ok := present[path]; ok {
+			continue
+		}
+		present[path] = device
+		if _, ok := known[path]; !ok {
+			appeared = append(appeared, device)
+		}
+	}
+	vanished := make([]*Ledger, 0)
+	for path, device := range known {
+		if _, ok := present[path]; !ok {
+			vanished = append(vanished, device)
+		}
+	}
+	sortDevices(vanished)
+
+	// Pair the appeared and vanished devices only if the pair is unambiguous
+	replaced := make(map[*Ledger]*Ledger)
+	for _, device := range appeared {
+		var match *Ledger
+		count := 0
+		for _, previous := range vanished {
+			if maybeSameDevice(previous.GetHidInfo(), device.GetHidInfo()) {
+				match = previous
+				count++
+			}
+		}
+		if count != 1 {
+			continue
+		}
+		rivals := 0
+		for _, other := range appeared {
+			if maybeSameDevice(match.GetHidInfo(), other.GetHidInfo()) {
+				rivals++
+			}
+		}
+		if rivals == 1 {
+			replaced[device] = match
+		}
+	}
+
+	for _, device := range devices {
+		info := device.GetHidInfo()
+		if present[info.Path] != device {
+			continue
+		}
+		previous, ok := known[info.Path]
+		if !ok {
+			previous, ok = replaced[device]
+		}
+		switch {
+		case !ok:
+			device.enumerator = enumerator
+			known[info.Path] = device
+			if !emit(DeviceEvent{Type: DeviceConnected, Device: device}) {
+				return false
+			}
+		case previous.GetHidInfo().Path != info.Path || previous.GetHidInfo().ProductID != info.ProductID:
+			previousInfo := *previous.GetHidInfo()
+			previous.Close()
+			delete(known, previousInfo.Path)
+			device.enumerator = enumerator
+			known[info.Path] = device
+			if !emit(DeviceEvent{Type: DeviceChanged, Device: device, Previous: &previousInfo}) {
+				return false
+			}
+		}
+	}
+
+	gone := make([]*Ledger, 0)
+	for path, device := range known {
+		if _, ok := present[path]; !ok {
+			gone = append(gone, device)
+			delete(known, path)
+		}
+	}
+	sortDevices(gone)
+	for _, device := range gone {
+		device.Close()
+		if !emit(DeviceEvent{Type: DeviceDisconnected, Device: device}) {
+			return false
+		}
+	}
+	return true
+}
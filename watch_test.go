@@ -0,0 +1,137 @@
+package ledger
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Enumerator with the device list changed by the test
+type fakeEnumerator struct {
+	mutex   sync.Mutex
+	devices []IHidDevice
+	err     error
+}
+
+func (enumerator *fakeEnumerator) set(devices []IHidDevice, err error) {
+	enumerator.mutex.Lock()
+	defer enumerator.mutex.Unlock()
+	enumerator.devices = devices
+	enumerator.err = err
+}
+
+func (enumerator *fakeEnumerator) Enumerate() ([]*Ledger, error) {
+	enumerator.mutex.Lock()
+	defer enumerator.mutex.Unlock()
+	if enumerator.err != nil {
+		return nil, enumerator.err
+	}
+	devices := make([]*Ledger, 0, len(enumerator.devices))
+	for _, hid := range enumerator.devices {
+		devices = append(devices, NewLedger(hid))
+	}
+	return devices, nil
+}
+
+// Wait for the next event
+func nextEvent(t *testing.T, events <-chan DeviceEvent, expected DeviceEventType, path string) DeviceEvent {
+	select {
+	case event, ok := <-events:
+		if !ok {
+			t.Fatalf("events channel is closed, expected %v\n", expected)
+		}
+		if event.Type != expected || (path != "" && event.Device.GetHidInfo().Path != path) {
+			t.Fatalf("WRONG event %v %+v, expected %v of %v\n", event.Type, event, expected, path)
+		}
+		return event
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timeout waiting for %v event\n", expected)
+	}
+	return DeviceEvent{}
+}
+
+func TestWatch(t *testing.T) {
+	dashboard := newTestEmulator(t)
+	dashboard.Info = HidDeviceInfo{Path: "a", VendorID: LedgerUSBVendorID, ProductID: 0x4011, SerialNumber: "0001"}
+	app := newTestEmulator(t)
+	app.Info = HidDeviceInfo{Path: "a2", VendorID: LedgerUSBVendorID, ProductID: 0x4015, SerialNumber: "0001"}
+	other := newTestEmulator(t)
+	other.Info = HidDeviceInfo{Path: "b", VendorID: LedgerUSBVendorID, ProductID: 0x0001}
+
+	enumerator := &fakeEnumerator{devices: []IHidDevice{other, dashboard}}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events := (&Watcher{Enumerator: enumerator, Interval: 10 * time.Millisecond}).Watch(ctx)
+
+	connected := nextEvent(t, events, DeviceConnected, "a")
+	otherConnected := nextEvent(t, events, DeviceConnected, "b")
+
+	// The app is opened, the device is re-enumerated with new product id
+	enumerator.set([]IHidDevice{other, app}, nil)
+	changed := nextEvent(t, events, DeviceChanged, "a2")
+	if changed.Previous == nil || changed.Previous.ProductID != 0x4011 {
+		t.Fatalf("WRONG previous device info %+v\n", changed.Previous)
+	}
+	if _, err := connected.Device.GetVersion(); err == nil {
+		t.Fatalf("changed device is not closed\n")
+	}
+
+	enumerator.set([]IHidDevice{app}, nil)
+	disconnected := nextEvent(t, events, DeviceDisconnected, "b")
+	if disconnected.Device != otherConnected.Device {
+		t.Fatalf("disconnected device differs from the connected one\n")
+	}
+
+	enumerator.set(nil, fmt.Errorf("enumeration error"))
+	if event := nextEvent(t, events, DeviceEnumerationFailed, ""); event.Err == nil {
+		t.Fatalf("enumeration error is missing\n")
+	}
+
+	cancel()
+	for range events {
+	}
+}
+
+func TestWatchSameModel(t *testing.T) {
+	// Ledger firmware reports the same serial number on every device of the model
+	first := newTestEmulator(t)
+	first.Info = HidDeviceInfo{Path: "a", VendorID: LedgerUSBVendorID, ProductID: 0x4011, SerialNumber: "0001"}
+	second := newTestEmulator(t)
+	second.Info = HidDeviceInfo{Path: "b", VendorID: LedgerUSBVendorID, ProductID: 0x4011, SerialNumber: "0001"}
+
+	enumerator := &fakeEnumerator{devices: []IHidDevice{first}}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events := (&Watcher{Enumerator: enumerator, Interval: 10 * time.Millisecond}).Watch(ctx)
+
+	nextEvent(t, events, DeviceConnected, "a")
+	enumerator.set([]IHidDevice{first, second}, nil)
+	nextEvent(t, events, DeviceConnected, "b")
+
+	// Both devices re-enumerated at once cannot be paired by the serial number
+	firstApp := newTestEmulator(t)
+	firstApp.Info = HidDeviceInfo{Path: "a2", VendorID: LedgerUSBVendorID, ProductID: 0x4015, SerialNumber: "0001"}
+	secondApp := newTestEmulator(t)
+	secondApp.Info = HidDeviceInfo{Path: "b2", VendorID: LedgerUSBVendorID, ProductID: 0x4015, SerialNumber: "0001"}
+	enumerator.set([]IHidDevice{firstApp, secondApp}, nil)
+	nextEvent(t, events, DeviceConnected, "a2")
+	nextEvent(t, events, DeviceConnected, "b2")
+	nextEvent(t, events, DeviceDisconnected, "a")
+	nextEvent(t, events, DeviceDisconnected, "b")
+
+	// Only one device is re-enumerated
+	enumerator.set([]IHidDevice{first, secondApp}, nil)
+	changed := nextEvent(t, events, DeviceChanged, "a")
+	if changed.Previous == nil || changed.Previous.Path != "a2" {
+		t.Fatalf("WRONG previous device info %+v\n", changed.Previous)
+	}
+
+	enumerator.set([]IHidDevice{first}, nil)
+	nextEvent(t, events, DeviceDisconnected, "b2")
+
+	cancel()
+	for range events {
+	}
+}